@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anans9/ai-git/internal/cache"
+	"github.com/anans9/ai-git/internal/config"
+	"github.com/anans9/ai-git/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage cached AI responses",
+	Long: `Manage the cache of AI responses stored in ~/.cache/ai-git.
+
+Responses are keyed on the provider, model, prompt template and the diff
+that was sent, so re-running a command on unchanged changes is instant and free.
+
+Examples:
+  ai-git cache stats                     # Show cache size and entry count
+  ai-git cache clear                     # Remove all cached responses
+  ai-git commit --no-cache               # Bypass the cache for one commit`,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show cache statistics",
+	Long:  `Show the number of cached responses, their total size and age.`,
+	RunE:  runCacheStats,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Clear cached responses",
+	Long:  `Remove all cached AI responses.`,
+	RunE:  runCacheClear,
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+
+	cacheClearCmd.Flags().BoolP("force", "f", false, "Clear without confirmation")
+}
+
+func runCacheStats(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui := ui.NewUI(cfg.UI.Color, cfg.UI.Interactive)
+
+	responseCache, err := cache.NewFromConfig(cfg.AI.Cache)
+	if err != nil {
+		ui.Error("Invalid cache configuration: %v", err)
+		return err
+	}
+
+	stats, err := responseCache.Stats()
+	if err != nil {
+		ui.Error("Failed to read cache: %v", err)
+		return err
+	}
+
+	ui.Header("AI Response Cache")
+
+	ui.Printf("  Enabled: %t", cfg.AI.Cache.Enabled)
+	ui.Printf("  Location: %s", stats.Dir)
+	ui.Printf("  TTL: %s", cfg.AI.Cache.TTL)
+	ui.Printf("  Max Size: %d MB", cfg.AI.Cache.MaxSizeMB)
+	ui.Print("")
+	ui.Printf("  Entries: %d (%d expired)", stats.Entries, stats.Expired)
+	ui.Printf("  Size: %s", formatBytes(stats.Size))
+	if stats.Entries > 0 {
+		ui.Printf("  Oldest: %s", stats.Oldest.Format(time.RFC3339))
+		ui.Printf("  Newest: %s", stats.Newest.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func runCacheClear(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui := ui.NewUI(cfg.UI.Color, cfg.UI.Interactive)
+
+	force, _ := cmd.Flags().GetBool("force")
+	if !force {
+		confirmed, err := ui.Confirm("Remove all cached AI responses?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			ui.Info("Cache clear cancelled")
+			return nil
+		}
+	}
+
+	responseCache, err := cache.NewFromConfig(cfg.AI.Cache)
+	if err != nil {
+		ui.Error("Invalid cache configuration: %v", err)
+		return err
+	}
+
+	if err := responseCache.Clear(); err != nil {
+		ui.Error("Failed to clear cache: %v", err)
+		return err
+	}
+
+	ui.Success("Cache cleared")
+	return nil
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -3,12 +3,16 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/anans9/ai-git/internal/ai"
 	"github.com/anans9/ai-git/internal/config"
 	"github.com/anans9/ai-git/internal/git"
+	"github.com/anans9/ai-git/internal/prompt"
 	"github.com/anans9/ai-git/internal/ui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -27,6 +31,7 @@ Examples:
   ai-git commit --message "fix: custom message"  # Use custom message
   ai-git commit --type feat        # Generate message with specific type
   ai-git commit --push             # Commit and push to remote
+  ai-git commit --candidates 3     # Pick from three generated messages
   ai-git commit --dry-run          # Show what would be committed without doing it`,
 	RunE: runCommit,
 }
@@ -42,8 +47,21 @@ var (
 	noEdit        bool
 	showDiff      bool
 	maxDiffLines  int
+	noStream      bool
+	candidates    int
+	noCache       bool
 )
 
+// Extra entries shown after the generated candidates in the selection list
+const (
+	candidateRegenerate = "↻ Regenerate suggestions"
+	candidateEdit       = "✎ Edit message"
+)
+
+// recentCommitLimit is the number of commit subjects available to prompt
+// templates
+const recentCommitLimit = 5
+
 func init() {
 	commitCmd.Flags().StringVarP(&commitMessage, "message", "m", "", "Use custom commit message instead of AI generation")
 	commitCmd.Flags().StringVarP(&commitType, "type", "t", "", "Commit type (feat, fix, docs, style, refactor, test, chore)")
@@ -54,7 +72,10 @@ func init() {
 	commitCmd.Flags().BoolVar(&amendCommit, "amend", false, "Amend the previous commit")
 	commitCmd.Flags().BoolVar(&noEdit, "no-edit", false, "Don't open editor for message editing")
 	commitCmd.Flags().BoolVar(&showDiff, "show-diff", false, "Show diff before generating commit message")
-	commitCmd.Flags().IntVar(&maxDiffLines, "max-diff-lines", 1000, "Maximum number of diff lines to analyze")
+	commitCmd.Flags().IntVar(&maxDiffLines, "max-diff-lines", 1000, "Maximum number of diff lines to analyze when summarization is disabled")
+	commitCmd.Flags().IntVar(&candidates, "candidates", 1, "Number of commit message suggestions to choose from")
+	commitCmd.Flags().BoolVar(&noCache, "no-cache", false, "Don't use cached AI responses")
+	commitCmd.Flags().BoolVar(&noStream, "no-stream", false, "Wait for the full message instead of streaming it")
 
 	// Bind flags to viper for configuration
 	viper.BindPFlag("git.auto_stage", commitCmd.Flags().Lookup("auto-stage"))
@@ -83,6 +104,12 @@ func runCommit(cmd *cobra.Command, args []string) error {
 	if showDiff {
 		cfg.UI.ShowDiff = true
 	}
+	if noStream {
+		cfg.AI.Stream = false
+	}
+	if noCache {
+		cfg.AI.Cache.Enabled = false
+	}
 
 	// Create UI instance
 	ui := ui.NewUI(cfg.UI.Color, cfg.UI.Interactive && !noEdit)
@@ -161,13 +188,22 @@ func runCommit(cmd *cobra.Command, args []string) error {
 
 	// Get commit message
 	var finalMessage string
+	var selected bool
 
 	if commitMessage != "" {
 		// Use provided message
 		finalMessage = commitMessage
+	} else if candidates > 1 {
+		// Let the user pick one of several AI suggestions
+		finalMessage, err = selectCommitMessage(cfg, ui, gitClient, diff, candidates)
+		if err != nil {
+			ui.Error("Failed to generate commit message: %v", err)
+			return err
+		}
+		selected = true
 	} else {
 		// Generate AI-powered commit message
-		finalMessage, err = generateCommitMessage(cfg, ui, diff)
+		finalMessage, err = generateCommitMessage(cfg, ui, gitClient, diff)
 		if err != nil {
 			ui.Error("Failed to generate commit message: %v", err)
 			return err
@@ -186,7 +222,7 @@ func runCommit(cmd *cobra.Command, args []string) error {
 	}
 
 	// Allow user to edit message if interactive and not disabled
-	if cfg.UI.Interactive && !noEdit {
+	if cfg.UI.Interactive && !noEdit && !selected {
 		ui.Header("Generated Commit Message")
 		ui.Highlight(finalMessage)
 
@@ -196,7 +232,7 @@ func runCommit(cmd *cobra.Command, args []string) error {
 		}
 
 		if !confirmed {
-			editedMessage, err := ui.Input("Enter commit message", finalMessage)
+			editedMessage, err := ui.Edit("Edit the commit message", finalMessage)
 			if err != nil {
 				return err
 			}
@@ -275,33 +311,328 @@ func runCommit(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func generateCommitMessage(cfg *config.Config, ui *ui.UI, diff *git.Diff) (string, error) {
+func generateCommitMessage(cfg *config.Config, ui *ui.UI, gitClient *git.Client, diff *git.Diff) (string, error) {
+	aiClient, diffContent, err := prepareCommitGeneration(cfg, ui, gitClient, diff)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := aiContext(cfg, "commit")
+	defer cancel()
+
+	if cfg.AI.Structured && !cfg.AI.Stream {
+		ui.StartSpinner(fmt.Sprintf("Generating commit message using %s...", aiClient.GetProviderName()))
+
+		messages, err := aiClient.GenerateCommits(ctx, diffContent, 1)
+		ui.StopSpinner()
+		if err != nil {
+			return "", fmt.Errorf("AI generation failed: %w", aiError(ctx, cfg, "commit", err))
+		}
+		reportFallback(cfg, ui, aiClient)
+
+		return messages[0].String(), nil
+	}
+
+	var message string
+	if cfg.AI.Stream {
+		// Render tokens as they arrive instead of showing a spinner
+		ui.Info("Generating commit message using %s...", aiClient.GetProviderName())
+		if cfg.AI.Structured {
+			// The message streams as plain text and is parsed once complete
+			var structured ai.CommitMessage
+			structured, err = aiClient.StreamCommit(ctx, diffContent, ui.PrintStream)
+			message = structured.String()
+		} else {
+			message, err = aiClient.StreamCommitMessage(ctx, diffContent, ui.PrintStream)
+		}
+		ui.Print("")
+		if err != nil {
+			return "", fmt.Errorf("AI generation failed: %w", aiError(ctx, cfg, "commit", err))
+		}
+		reportFallback(cfg, ui, aiClient)
+
+		if cfg.AI.Structured {
+			return message, nil
+		}
+	} else {
+		ui.StartSpinner(fmt.Sprintf("Generating commit message using %s...", aiClient.GetProviderName()))
+
+		message, err = aiClient.GenerateCommitMessage(ctx, diffContent)
+		if err != nil {
+			ui.StopSpinner()
+			return "", fmt.Errorf("AI generation failed: %w", aiError(ctx, cfg, "commit", err))
+		}
+
+		ui.StopSpinner()
+		reportFallback(cfg, ui, aiClient)
+	}
+
+	return cleanCommitMessage(message)
+}
+
+// selectCommitMessage generates several candidate messages and lets the user
+// pick one, regenerate the list, or edit a suggestion by hand
+func selectCommitMessage(cfg *config.Config, ui *ui.UI, gitClient *git.Client, diff *git.Diff, n int) (string, error) {
+	aiClient, diffContent, err := prepareCommitGeneration(cfg, ui, gitClient, diff)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		ui.StartSpinner(fmt.Sprintf("Generating %d commit messages using %s...", n, aiClient.GetProviderName()))
+
+		ctx, cancel := aiContext(cfg, "commit")
+		generated, err := generateCandidates(ctx, cfg, aiClient, diffContent, n)
+		if err != nil {
+			err = aiError(ctx, cfg, "commit", err)
+		}
+		cancel()
+
+		ui.StopSpinner()
+
+		if err != nil {
+			return "", fmt.Errorf("AI generation failed: %w", err)
+		}
+		reportFallback(cfg, ui, aiClient)
+
+		messages := []string{}
+		seen := map[string]bool{}
+		for _, message := range generated {
+			if seen[message] {
+				continue
+			}
+			seen[message] = true
+			messages = append(messages, message)
+		}
+
+		if len(messages) == 0 {
+			return "", fmt.Errorf("AI generated empty commit message")
+		}
+
+		// Show only the header of multi-line messages in the list
+		items := []string{}
+		for _, message := range messages {
+			items = append(items, strings.SplitN(message, "\n", 2)[0])
+		}
+		items = append(items, candidateRegenerate, candidateEdit)
+		index, _, err := ui.Select("Choose a commit message", items)
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case index < len(messages):
+			return messages[index], nil
+		case items[index] == candidateRegenerate:
+			// Ask the provider again instead of replaying the cached list
+			aiClient.RefreshCache()
+			continue
+		default:
+			return editCandidate(ui, messages, items[:len(messages)])
+		}
+	}
+}
+
+// editCandidate lets the user pick one of messages, listed by their
+// headers in items, and edit it in full
+func editCandidate(ui *ui.UI, messages, items []string) (string, error) {
+	index := 0
+	if len(messages) > 1 {
+		var err error
+		if index, _, err = ui.Select("Choose a commit message to edit", items); err != nil {
+			return "", err
+		}
+	}
+	return ui.Edit("Edit the commit message", messages[index])
+}
+
+// generateCandidates generates up to n commit messages, as structured
+// messages or as single lines depending on the configuration
+func generateCandidates(ctx context.Context, cfg *config.Config, aiClient *ai.Client, diffContent string, n int) ([]string, error) {
+	var messages []string
+
+	if cfg.AI.Structured {
+		generated, err := aiClient.GenerateCommits(ctx, diffContent, n)
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range generated {
+			messages = append(messages, message.String())
+		}
+		return messages, nil
+	}
+
+	generated, err := aiClient.GenerateCommitMessages(ctx, diffContent, n)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range generated {
+		message, err := cleanCommitMessage(candidate)
+		if err != nil {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// reportFallback tells the user which providers failed and which provider
+// produced the message when the fallback chain was used, and warns when the
+// heuristic provider ignored the configured language
+func reportFallback(cfg *config.Config, ui *ui.UI, aiClient *ai.Client) {
+	for _, err := range aiClient.FallbackErrors() {
+		ui.Warning("%v", err)
+	}
+	if aiClient.GetProviderName() != cfg.AI.Provider {
+		ui.Info("Generated by fallback provider %s", aiClient.GetProviderName())
+	}
+	if language := prompt.LanguageName(cfg.AI.Language); language != "" && aiClient.HeuristicResponse() {
+		ui.Warning("The heuristic provider writes English; ai.language (%s) does not apply to it", language)
+	}
+}
+
+// aiContext returns the context of an AI operation, which ends when the
+// operation's timeout passes or the user presses Ctrl-C. Calling cancel
+// restores the default handling of Ctrl-C.
+func aiContext(cfg *config.Config, operation string) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+
+	// Timeouts were validated when the AI client was created
+	timeout, _ := cfg.AI.Timeouts.Timeout(operation)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// aiError explains the failure of an AI operation that was interrupted or
+// ran out of time
+func aiError(ctx context.Context, cfg *config.Config, operation string, err error) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return fmt.Errorf("interrupted")
+	case context.DeadlineExceeded:
+		timeout, _ := cfg.AI.Timeouts.Timeout(operation)
+		return fmt.Errorf("timed out after %s (raise ai.timeouts.%s for slow models)", timeout, operation)
+	}
+	return err
+}
+
+// prepareCommitGeneration creates the AI client and formats the diff for it
+func prepareCommitGeneration(cfg *config.Config, ui *ui.UI, gitClient *git.Client, diff *git.Diff) (*ai.Client, string, error) {
 	// Create AI client
 	aiClient, err := ai.NewClient(cfg)
 	if err != nil {
-		return "", fmt.Errorf("failed to initialize AI client: %w", err)
+		return nil, "", fmt.Errorf("failed to initialize AI client: %w", err)
 	}
+	aiClient.SetCommand("commit")
+	diff = redactDiff(ui, aiClient, diff)
+	aiClient.SetDiff(diff)
+	aiClient.SetPromptContext(newPromptContext(cfg, gitClient, diff))
 
 	// Prepare diff content for AI analysis
-	diffContent := formatDiffForAI(diff, cfg.Git.MaxDiffLines)
+	diffContent, err := diffForAI(cfg, ui, aiClient, diff)
+	if err != nil {
+		return nil, "", err
+	}
 
 	if strings.TrimSpace(diffContent) == "" {
-		return "", fmt.Errorf("no diff content available for analysis")
+		return nil, "", fmt.Errorf("no diff content available for analysis")
 	}
 
-	ui.StartSpinner(fmt.Sprintf("Generating commit message using %s...", aiClient.GetProviderName()))
+	return aiClient, diffContent, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// newPromptContext collects the repository context for prompt templates.
+// Branch and history are best effort, since a new repository has neither.
+func newPromptContext(cfg *config.Config, gitClient *git.Client, diff *git.Diff) prompt.Context {
+	data := prompt.Context{
+		Repo: filepath.Base(gitClient.GetRepoPath()),
+		Stats: prompt.Stats{
+			Files:     diff.Stats.Files,
+			Additions: diff.Stats.Additions,
+			Deletions: diff.Stats.Deletions,
+		},
+		Types:  cfg.Templates.Patterns.Types,
+		Scopes: cfg.Templates.Patterns.Scopes,
+	}
+
+	for _, file := range diff.Files {
+		data.Files = append(data.Files, prompt.File{
+			Path:      file.Path,
+			OldPath:   file.OldPath,
+			Status:    file.Status,
+			Additions: file.Additions,
+			Deletions: file.Deletions,
+		})
+	}
+
+	if branch, err := gitClient.GetCurrentBranch(); err == nil {
+		data.Branch = branch
+		data.Ticket = prompt.TicketFromBranch(branch)
+	}
+
+	if commits, err := gitClient.GetCommitHistory(recentCommitLimit); err == nil {
+		for _, commit := range commits {
+			data.RecentCommits = append(data.RecentCommits, strings.SplitN(commit.Message, "\n", 2)[0])
+		}
+	}
+
+	if cfg.AI.Examples.Enabled {
+		data.Examples = historyExamples(cfg, gitClient, diff)
+	}
+
+	return data
+}
 
-	message, err := aiClient.GenerateCommitMessage(ctx, diffContent)
+// redactDiff returns a copy of diff with suspected secrets replaced by
+// placeholders, warning about each file that contained any. The client
+// redacts every prompt as well; doing it here first tells the user which
+// files were affected.
+func redactDiff(ui *ui.UI, aiClient *ai.Client, diff *git.Diff) *git.Diff {
+	redacted := &git.Diff{Stats: diff.Stats, Files: make([]git.FileDiff, 0, len(diff.Files))}
+
+	for _, file := range diff.Files {
+		var kinds []string
+		file.Content, kinds = aiClient.Redact(file.Content)
+		if len(kinds) > 0 {
+			ui.Warning("Redacted suspected secrets in %s (%s)", file.Path, strings.Join(kinds, ", "))
+		}
+		redacted.Files = append(redacted.Files, file)
+	}
+
+	return redacted
+}
+
+// historyExamples picks commits from the repository's history as examples of
+// its commit style, preferring commits that touched the changed files
+func historyExamples(cfg *config.Config, gitClient *git.Client, diff *git.Diff) []string {
+	commits, err := gitClient.GetCommitHistoryWithFiles(cfg.AI.Examples.Scan)
 	if err != nil {
-		ui.StopSpinner()
-		return "", fmt.Errorf("AI generation failed: %w", err)
+		return nil
 	}
 
-	ui.StopSpinner()
+	history := make([]ai.HistoryCommit, 0, len(commits))
+	for _, commit := range commits {
+		history = append(history, ai.HistoryCommit{Message: commit.Message, Files: commit.Files})
+	}
+
+	paths := make([]string, 0, len(diff.Files))
+	for _, file := range diff.Files {
+		paths = append(paths, file.Path)
+	}
+
+	return ai.SelectExamples(history, paths, cfg.AI.Examples.Count, cfg.Templates.Patterns.Conventional)
+}
 
+// cleanCommitMessage strips markdown from a generated message and keeps
+// only its subject line
+func cleanCommitMessage(message string) (string, error) {
 	// Clean up and validate the generated message
 	message = strings.TrimSpace(message)
 	if message == "" {
@@ -313,12 +644,83 @@ func generateCommitMessage(cfg *config.Config, ui *ui.UI, diff *git.Diff) (strin
 	message = strings.ReplaceAll(message, "`", "")
 
 	// Split into lines and take the first line as the main message
-	lines := strings.Split(message, "\n")
+	lines := strings.Split(strings.TrimSpace(message), "\n")
 	message = strings.TrimSpace(lines[0])
+	if message == "" {
+		return "", fmt.Errorf("AI generated empty commit message")
+	}
 
 	return message, nil
 }
 
+// diffForAI formats the diff for the AI. With summarization enabled the diff
+// is limited by tokens rather than lines, and a diff that exceeds the token
+// budget is summarized file group by file group instead of truncated.
+func diffForAI(cfg *config.Config, ui *ui.UI, aiClient *ai.Client, diff *git.Diff) (string, error) {
+	if !cfg.AI.Summarize.Enabled {
+		diffContent := formatDiffForAI(diff, cfg.Git.MaxDiffLines)
+		if strings.Contains(diffContent, "... (truncated") || strings.Contains(diffContent, "... (file truncated)") {
+			ui.Warning("Diff truncated by line limits; the message may not reflect all changes")
+		}
+
+		// Lines are a poor measure of size, so also keep within the model's context
+		if tokens, budget := aiClient.EstimateTokens(diffContent), aiClient.DiffBudget(); tokens > budget {
+			ui.Warning("Diff is about %d tokens, more than the %d-token budget for %s; truncating it", tokens, budget, aiClient.Model())
+			diffContent = aiClient.TruncateTokens(diffContent, budget)
+		}
+		return diffContent, nil
+	}
+
+	header := fmt.Sprintf("Files changed: %d, Insertions: %d, Deletions: %d\n\n",
+		diff.Stats.Files, diff.Stats.Additions, diff.Stats.Deletions)
+
+	parts := make([]string, 0, len(diff.Files))
+	for _, file := range diff.Files {
+		parts = append(parts, formatFileForAI(file))
+	}
+
+	if !aiClient.NeedsSummary(parts) {
+		return header + strings.Join(parts, "\n"), nil
+	}
+
+	ui.StartSpinner(fmt.Sprintf("Summarizing %d files that exceed the token budget...", len(diff.Files)))
+
+	ctx, cancel := aiContext(cfg, "summarize")
+	defer cancel()
+
+	summary, err := aiClient.SummarizeDiff(ctx, parts)
+	ui.StopSpinner()
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize diff: %w", aiError(ctx, cfg, "summarize", err))
+	}
+
+	return header + "The diff is too large to include in full. Summaries of all changes:\n\n" + summary, nil
+}
+
+// formatFileForAI formats the diff of a single file without limiting its
+// number of lines
+func formatFileForAI(file git.FileDiff) string {
+	var result strings.Builder
+
+	result.WriteString(fmt.Sprintf("File: %s (Status: %s)\n", file.Path, file.Status))
+	if file.Additions > 0 || file.Deletions > 0 {
+		result.WriteString(fmt.Sprintf("Changes: +%d -%d\n", file.Additions, file.Deletions))
+	}
+
+	if file.Content != "" {
+		for _, line := range strings.Split(file.Content, "\n") {
+			// Skip binary files or very long lines
+			if len(line) > 200 {
+				result.WriteString("... (line too long)\n")
+			} else {
+				result.WriteString(line + "\n")
+			}
+		}
+	}
+
+	return result.String()
+}
+
 func formatDiffForAI(diff *git.Diff, maxLines int) string {
 	var result strings.Builder
 
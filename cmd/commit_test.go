@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commitCassette holds the response recorded for the change made by
+// newTestRepo. Record it again after changing the commit prompt with:
+//
+//	ai-git commit --no-edit --record cmd/testdata/commit.yaml
+//
+// in a repository prepared the same way.
+const commitCassette = "testdata/commit.yaml"
+
+// newTestRepo creates a repository with one commit and a staged change to
+// greet.go
+func newTestRepo(t *testing.T) (string, *git.Repository) {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to init repository: %v", err)
+	}
+	workTree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get work tree: %v", err)
+	}
+
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "greet.go"), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write greet.go: %v", err)
+		}
+		if _, err := workTree.Add("greet.go"); err != nil {
+			t.Fatalf("failed to stage greet.go: %v", err)
+		}
+	}
+
+	write("package greet\n\nfunc Hello() string {\n\treturn \"hello\"\n}\n")
+	_, err = workTree.Commit("feat: add greeting", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(0, 0)},
+	})
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	write("package greet\n\nfunc Hello(name string) string {\n\treturn \"hello \" + name\n}\n")
+
+	return dir, repo
+}
+
+// TestCommitReplay runs ai-git commit against a recorded response, without
+// network access
+func TestCommitReplay(t *testing.T) {
+	cassette, err := filepath.Abs(commitCassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	configPath := filepath.Join(home, ".ai-git.yaml")
+	configData := `ai:
+  provider: replay
+  model: gpt-4
+  cache:
+    enabled: false
+  usage:
+    enabled: false
+  providers:
+    replay:
+      type: replay
+      cassette: ` + cassette + `
+      enabled: true
+ui:
+  color: false
+  interactive: false
+`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, repo := newTestRepo(t)
+	t.Chdir(dir)
+
+	rootCmd.SetArgs([]string{"--config", configPath, "commit", "--no-edit"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "feat(greet): take the name to greet\n\nHello now greets the caller by name instead of returning a fixed greeting."
+	if got := strings.TrimSpace(commit.Message); got != want {
+		t.Errorf("commit message = %q, want %q", got, want)
+	}
+}
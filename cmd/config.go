@@ -3,8 +3,10 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strconv"
 	"strings"
 	"time"
@@ -110,8 +112,16 @@ var configProvidersSetCmd = &cobra.Command{
 Examples:
   ai-git config providers set openai api_key sk-...
   ai-git config providers set anthropic api_key sk-ant-...
+  ai-git config providers set gemini api_key AIza...
   ai-git config providers set local base_url http://localhost:11434
-  ai-git config providers set openai model gpt-4`,
+  ai-git config providers set openai model gpt-4
+  ai-git config providers set azure endpoint https://my-resource.openai.azure.com
+  ai-git config providers set azure deployment gpt-4o-prod
+  ai-git config providers set vllm type openai-compatible
+  ai-git config providers set vllm base_url http://localhost:8000/v1
+  ai-git config providers set vllm headers.X-Team platform
+  ai-git config providers set gateway type exec
+  ai-git config providers set gateway command /usr/local/bin/llm-gateway`,
 	Args: cobra.ExactArgs(3),
 	RunE: runConfigProvidersSet,
 }
@@ -124,6 +134,19 @@ If no provider is specified, tests the current default provider.`,
 	RunE: runConfigProvidersTest,
 }
 
+var configProvidersModelsCmd = &cobra.Command{
+	Use:   "models [provider]",
+	Short: "List models installed on a local provider",
+	Long: `List the models installed on a local AI server such as Ollama.
+If no provider is specified, lists the models of the local provider.
+
+Examples:
+  ai-git config providers models
+  ai-git config providers models local`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConfigProvidersModels,
+}
+
 var configResetCmd = &cobra.Command{
 	Use:   "reset [key]",
 	Short: "Reset configuration to defaults",
@@ -147,6 +170,7 @@ func init() {
 	configProvidersCmd.AddCommand(configProvidersListCmd)
 	configProvidersCmd.AddCommand(configProvidersSetCmd)
 	configProvidersCmd.AddCommand(configProvidersTestCmd)
+	configProvidersCmd.AddCommand(configProvidersModelsCmd)
 	configCmd.AddCommand(configProvidersCmd)
 
 	// Flags
@@ -232,8 +256,53 @@ func runConfigShow(cmd *cobra.Command, args []string) error {
 	ui.Highlight("AI Settings:")
 	ui.Printf("  Provider: %s", cfg.AI.Provider)
 	ui.Printf("  Model: %s", cfg.AI.Model)
+	if info, ok := cfg.LookupModel(cfg.AI.Model); ok {
+		ui.Printf("  Context Window: %d tokens (Max Output: %d, Tokenizer: %s)", info.ContextWindow, info.MaxOutput, info.Tokenizer)
+	}
 	ui.Printf("  Temperature: %.1f", cfg.AI.Temperature)
 	ui.Printf("  Max Tokens: %d", cfg.AI.MaxTokens)
+	if cfg.AI.Language != "" {
+		ui.Printf("  Language: %s", cfg.AI.Language)
+	}
+	ui.Printf("  Stream: %t", cfg.AI.Stream)
+	ui.Printf("  Structured Messages: %t", cfg.AI.Structured)
+	ui.Printf("  Cache: %t (TTL: %s, Max Size: %d MB)", cfg.AI.Cache.Enabled, cfg.AI.Cache.TTL, cfg.AI.Cache.MaxSizeMB)
+	if len(cfg.AI.Fallback) > 0 {
+		ui.Printf("  Fallback: %s", strings.Join(cfg.AI.Fallback, " → "))
+	}
+	ui.Printf("  Usage Tracking: %t", cfg.AI.Usage.Enabled)
+	ui.Printf("  Summarize Large Diffs: %t (Budget: %d tokens)", cfg.AI.Summarize.Enabled, cfg.AI.Summarize.MaxTokens)
+	ui.Printf("  History Examples: %t (Count: %d, Scan: %d commits)", cfg.AI.Examples.Enabled, cfg.AI.Examples.Count, cfg.AI.Examples.Scan)
+	entropy := "off"
+	if cfg.AI.Redact.Entropy > 0 {
+		entropy = fmt.Sprintf("%.1f bits", cfg.AI.Redact.Entropy)
+	}
+	ui.Printf("  Redact Secrets: %t (Entropy: %s, Custom Patterns: %d)", cfg.AI.Redact.Enabled, entropy, len(cfg.AI.Redact.Patterns))
+	ui.Printf("  Retry: %d attempts (Delay: %s - %s)", cfg.AI.Retry.MaxAttempts, cfg.AI.Retry.InitialDelay, cfg.AI.Retry.MaxDelay)
+	ui.Printf("  Timeouts: Commit %s, Review %s, Summarize %s", cfg.AI.Timeouts.Commit, cfg.AI.Timeouts.Review, cfg.AI.Timeouts.Summarize)
+	if network := cfg.AI.Network; network != (config.NetworkConfig{}) {
+		var settings []string
+		if network.Proxy != "" {
+			proxy := network.Proxy
+			if proxyURL, err := url.Parse(proxy); err == nil {
+				proxy = proxyURL.Redacted()
+			}
+			settings = append(settings, "Proxy: "+proxy)
+		}
+		if network.NoProxy != "" {
+			settings = append(settings, "No Proxy: "+network.NoProxy)
+		}
+		if network.CACert != "" {
+			settings = append(settings, "CA Bundle: "+network.CACert)
+		}
+		if network.ClientCert != "" {
+			settings = append(settings, "Client Certificate: "+network.ClientCert)
+		}
+		if network.InsecureSkipVerify {
+			settings = append(settings, "TLS Verification: off")
+		}
+		ui.Printf("  Network: %s", strings.Join(settings, ", "))
+	}
 	ui.Print("")
 
 	// Git Configuration
@@ -334,24 +403,13 @@ func runConfigGet(cmd *cobra.Command, args []string) error {
 
 func runConfigEdit(cmd *cobra.Command, args []string) error {
 	configPath := config.GetConfigPath()
-	editor := os.Getenv("EDITOR")
-
-	if editor == "" {
-		// Try common editors
-		editors := []string{"nano", "vim", "vi", "code", "subl"}
-		for _, e := range editors {
-			if _, err := exec.LookPath(e); err == nil {
-				editor = e
-				break
-			}
-		}
-	}
-
+	editor := ui.Editor()
 	if editor == "" {
 		return fmt.Errorf("no editor found. Set $EDITOR environment variable")
 	}
 
-	execCmd := exec.Command(editor, configPath)
+	editorArgs := strings.Fields(editor)
+	execCmd := exec.Command(editorArgs[0], append(editorArgs[1:], configPath)...)
 	execCmd.Stdin = os.Stdin
 	execCmd.Stdout = os.Stdout
 	execCmd.Stderr = os.Stderr
@@ -410,7 +468,7 @@ func runConfigProvidersList(cmd *cobra.Command, args []string) error {
 
 	ui.Header("AI Providers")
 
-	headers := []string{"Provider", "Status", "Model", "API Key", "Base URL"}
+	headers := []string{"Provider", "Type", "Status", "Model", "API Key", "Base URL"}
 	rows := [][]string{}
 
 	for name, provider := range cfg.AI.Providers {
@@ -428,12 +486,16 @@ func runConfigProvidersList(cmd *cobra.Command, args []string) error {
 		}
 
 		baseURL := provider.BaseURL
+		if baseURL == "" {
+			baseURL = provider.Endpoint
+		}
 		if baseURL == "" {
 			baseURL = "Default"
 		}
 
 		rows = append(rows, []string{
 			name,
+			provider.ProviderType(name),
 			status,
 			provider.Model,
 			apiKeyStatus,
@@ -473,13 +535,45 @@ func runConfigProvidersSet(cmd *cobra.Command, args []string) error {
 	case "model":
 		provider.Model = value
 		ui.Success("Model set for provider %s: %s", providerName, value)
+	case "type":
+		provider.Type = value
+		ui.Success("Type set for provider %s: %s", providerName, value)
+	case "command":
+		provider.Command = value
+		ui.Success("Command set for provider %s: %s", providerName, value)
+	case "args":
+		provider.Args = strings.Fields(value)
+		ui.Success("Arguments set for provider %s: %s", providerName, value)
+	case "endpoint":
+		provider.Endpoint = value
+		ui.Success("Endpoint set for provider %s: %s", providerName, value)
+	case "deployment":
+		provider.Deployment = value
+		ui.Success("Deployment set for provider %s: %s", providerName, value)
+	case "api_version":
+		provider.APIVersion = value
+		ui.Success("API version set for provider %s: %s", providerName, value)
+	case "auth_header":
+		provider.AuthHeader = value
+		ui.Success("Auth header set for provider %s: %s", providerName, value)
+	case "auth_scheme":
+		provider.AuthScheme = value
+		ui.Success("Auth scheme set for provider %s: %s", providerName, value)
 	case "enabled":
 		enabled := strings.ToLower(value) == "true"
 		provider.Enabled = enabled
 		ui.Success("Provider %s %s", providerName, map[bool]string{true: "enabled", false: "disabled"}[enabled])
 	default:
-		ui.Error("Unknown provider configuration key: %s", key)
-		return fmt.Errorf("unknown key: %s", key)
+		header, isHeader := strings.CutPrefix(key, "headers.")
+		if !isHeader || header == "" {
+			ui.Error("Unknown provider configuration key: %s", key)
+			return fmt.Errorf("unknown key: %s", key)
+		}
+		if provider.Headers == nil {
+			provider.Headers = map[string]string{}
+		}
+		provider.Headers[header] = value
+		ui.Success("Header %s set for provider %s", header, providerName)
 	}
 
 	cfg.SetProvider(providerName, provider)
@@ -520,11 +614,24 @@ func runConfigProvidersTest(cmd *cobra.Command, args []string) error {
 			continue
 		}
 
+		// Local servers can only answer once the model is installed
+		if provider, err := ai.NewProvider(cfg, providerName); err == nil {
+			if local, ok := provider.(*ai.LocalProvider); ok {
+				if err := ensureLocalModel(ui, local); err != nil {
+					ui.Error("Provider %s test failed: %v", providerName, err)
+					cfg.AI.Provider = originalProvider
+					continue
+				}
+			}
+		}
+
 		ui.StartSpinner(fmt.Sprintf("Testing %s connection...", providerName))
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 		err = aiClient.TestConnection(ctx)
 		cancel()
+		stop()
 
 		ui.StopSpinner()
 
@@ -541,6 +648,112 @@ func runConfigProvidersTest(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// ensureLocalModel checks that the configured model is installed on the
+// local server and offers to pull it if it is missing
+func ensureLocalModel(ui *ui.UI, local *ai.LocalProvider) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	installed, err := local.HasModel(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if installed {
+		return nil
+	}
+
+	ui.Warning("Model %s is not installed on the local server", local.Model())
+	if !ui.IsInteractive() {
+		return fmt.Errorf("model %s not found (run 'ollama pull %s')", local.Model(), local.Model())
+	}
+
+	confirmed, err := ui.Confirm(fmt.Sprintf("Pull model %s?", local.Model()))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return fmt.Errorf("model %s not found", local.Model())
+	}
+
+	// Ctrl-C cancels the download rather than leaving the progress bar
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err = local.PullModel(ctx, func(progress ai.PullProgress) {
+		if progress.Total > 0 {
+			ui.ShowProgress(int(progress.Completed), int(progress.Total), progress.Status)
+		}
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			ui.Print("")
+			return fmt.Errorf("pull of %s interrupted", local.Model())
+		}
+		return err
+	}
+
+	ui.Success("Pulled model %s", local.Model())
+	return nil
+}
+
+func runConfigProvidersModels(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui := ui.NewUI(viper.GetBool("ui.color"), viper.GetBool("ui.interactive"))
+
+	providerName := "local"
+	if len(args) > 0 {
+		providerName = args[0]
+	}
+
+	provider, err := ai.NewProvider(cfg, providerName)
+	if err != nil {
+		return err
+	}
+
+	local, ok := provider.(*ai.LocalProvider)
+	if !ok {
+		return fmt.Errorf("provider %s does not support listing models", providerName)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	models, err := local.ListModels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+
+	if len(models) == 0 {
+		ui.Info("No models installed on %s", providerName)
+		return nil
+	}
+
+	ui.Header(fmt.Sprintf("Models on %s", providerName))
+
+	headers := []string{"Name", "Size", "Parameters", "Quantization", "Modified"}
+	var rows [][]string
+	for _, model := range models {
+		name := model.Name
+		if name == local.Model() || name == local.Model()+":latest" {
+			name += " *"
+		}
+		rows = append(rows, []string{
+			name,
+			formatBytes(model.Size),
+			model.Details.ParameterSize,
+			model.Details.QuantizationLevel,
+			model.ModifiedAt.Format("2006-01-02 15:04"),
+		})
+	}
+
+	ui.PrintTable(headers, rows)
+
+	return nil
+}
+
 func runConfigReset(cmd *cobra.Command, args []string) error {
 	ui := ui.NewUI(viper.GetBool("ui.color"), viper.GetBool("ui.interactive"))
 
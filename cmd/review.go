@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anans9/ai-git/internal/ai"
+	"github.com/anans9/ai-git/internal/config"
+	"github.com/anans9/ai-git/internal/git"
+	"github.com/anans9/ai-git/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reviewStaged bool
+	reviewFailOn string
+)
+
+var reviewCmd = &cobra.Command{
+	Use:   "review [range]",
+	Short: "Review changes with AI",
+	Long: `Review a diff with AI and print the problems found, grouped by file and
+line, each with a severity of low, medium or high.
+
+Without arguments the unstaged changes are reviewed. A range such as
+main..HEAD or origin/main...HEAD reviews the commits in it, and a single
+revision reviews everything between it and HEAD. The prompt can be changed
+under templates.prompts.code_review.
+
+With --fail-on the command exits with a non-zero status when a finding is at
+least that severe, so it can be used as a pre-push hook.
+
+Examples:
+  ai-git review                          # Review unstaged changes
+  ai-git review --staged                 # Review staged changes
+  ai-git review main..HEAD               # Review the commits on this branch
+  ai-git review @{u}.. --fail-on high    # Fail on high severity findings`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runReview,
+}
+
+func init() {
+	reviewCmd.Flags().BoolVar(&reviewStaged, "staged", false, "Review staged changes")
+	reviewCmd.Flags().StringVar(&reviewFailOn, "fail-on", "", "Exit with an error on findings of this severity or higher (low, medium, high)")
+}
+
+func runReview(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui := ui.NewUI(cfg.UI.Color, cfg.UI.Interactive)
+
+	if reviewStaged && len(args) > 0 {
+		return fmt.Errorf("--staged cannot be combined with a range")
+	}
+
+	failOn := ai.SeverityRank(strings.ToLower(reviewFailOn))
+	if reviewFailOn != "" && failOn == 0 {
+		return fmt.Errorf("invalid severity: %s (use low, medium or high)", reviewFailOn)
+	}
+
+	gitClient, err := git.NewClient("")
+	if err != nil {
+		ui.Error("Not a git repository or failed to initialize git client: %v", err)
+		return err
+	}
+
+	var diff *git.Diff
+	switch {
+	case len(args) > 0:
+		diff, err = gitClient.GetRangeDiff(args[0])
+	case reviewStaged:
+		diff, err = gitClient.GetStagedDiff()
+	default:
+		diff, err = gitClient.GetDiff()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get diff: %w", err)
+	}
+
+	if len(diff.Files) == 0 {
+		ui.Success("No changes to review")
+		return nil
+	}
+
+	aiClient, err := ai.NewClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize AI client: %w", err)
+	}
+	aiClient.SetCommand("review")
+	diff = redactDiff(ui, aiClient, diff)
+	aiClient.SetDiff(diff)
+	aiClient.SetPromptContext(newPromptContext(cfg, gitClient, diff))
+
+	parts := make([]string, 0, len(diff.Files))
+	for _, file := range diff.Files {
+		parts = append(parts, formatFileForAI(file))
+	}
+
+	ui.StartSpinner(fmt.Sprintf("Reviewing %d files...", len(diff.Files)))
+
+	ctx, cancel := aiContext(cfg, "review")
+	defer cancel()
+
+	findings, err := aiClient.Review(ctx, parts)
+	ui.StopSpinner()
+	if err != nil {
+		return fmt.Errorf("failed to review changes: %w", aiError(ctx, cfg, "review", err))
+	}
+
+	reportFallback(cfg, ui, aiClient)
+
+	if len(findings) == 0 {
+		ui.Success("No issues found")
+		return nil
+	}
+
+	printFindings(ui, findings)
+
+	counts := map[string]int{}
+	failed := 0
+	for _, finding := range findings {
+		counts[finding.Severity]++
+		if failOn > 0 && ai.SeverityRank(finding.Severity) >= failOn {
+			failed++
+		}
+	}
+
+	ui.Printf("")
+	ui.Info("%d findings: %d high, %d medium, %d low", len(findings),
+		counts[ai.SeverityHigh], counts[ai.SeverityMedium], counts[ai.SeverityLow])
+
+	if failed > 0 {
+		// Failing the gate is not a usage error
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d findings of %s severity or higher", failed, strings.ToLower(reviewFailOn))
+	}
+
+	return nil
+}
+
+// printFindings prints findings grouped by file. Findings are already sorted
+// by file and line.
+func printFindings(ui *ui.UI, findings []ai.Finding) {
+	file := ""
+	for i, finding := range findings {
+		if i == 0 || finding.File != file {
+			file = finding.File
+			name := file
+			if name == "" {
+				name = "(general)"
+			}
+			ui.Header(name)
+		}
+
+		location := "     "
+		if finding.Line > 0 {
+			location = fmt.Sprintf("L%-4d", finding.Line)
+		}
+		ui.PrintFinding(finding.Severity, location, finding.Message)
+		if finding.Suggestion != "" {
+			ui.Dim("               → %s", finding.Suggestion)
+		}
+	}
+}
@@ -56,15 +56,19 @@ func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.ai-git.yaml)")
 	rootCmd.PersistentFlags().Bool("verbose", false, "verbose output")
-	rootCmd.PersistentFlags().String("provider", "", "AI provider to use (openai, anthropic, local)")
+	rootCmd.PersistentFlags().String("provider", "", "AI provider to use (openai, anthropic, gemini, local)")
 	rootCmd.PersistentFlags().String("model", "", "AI model to use")
 	rootCmd.PersistentFlags().Bool("dry-run", false, "show what would be done without executing")
+	rootCmd.PersistentFlags().String("record", "", "record AI responses in a cassette file for the replay provider")
+	rootCmd.PersistentFlags().String("lang", "", "language of generated messages, such as de or German")
 
 	// Bind flags to viper
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 	viper.BindPFlag("provider", rootCmd.PersistentFlags().Lookup("provider"))
 	viper.BindPFlag("model", rootCmd.PersistentFlags().Lookup("model"))
 	viper.BindPFlag("dry-run", rootCmd.PersistentFlags().Lookup("dry-run"))
+	viper.BindPFlag("ai.record", rootCmd.PersistentFlags().Lookup("record"))
+	viper.BindPFlag("ai.language", rootCmd.PersistentFlags().Lookup("lang"))
 
 	// Add subcommands
 	rootCmd.AddCommand(commitCmd)
@@ -73,6 +77,9 @@ func init() {
 	rootCmd.AddCommand(workflowCmd)
 	rootCmd.AddCommand(templateCmd)
 	rootCmd.AddCommand(uninstallCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(usageCmd)
+	rootCmd.AddCommand(reviewCmd)
 }
 
 // initConfig reads in config file and ENV variables
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/anans9/ai-git/internal/config"
 	"github.com/anans9/ai-git/internal/ui"
@@ -581,12 +582,14 @@ func runTemplateValidate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Additional validation rules
-	if len(message) > 72 {
-		ui.Warning("Message is longer than 72 characters (current: %d)", len(message))
+	// Additional validation rules, counting characters rather than bytes so
+	// that subjects in other languages are measured correctly
+	length := utf8.RuneCountInString(message)
+	if length > 72 {
+		ui.Warning("Message is longer than 72 characters (current: %d)", length)
 	}
 
-	if len(message) > 50 {
+	if length > 50 {
 		ui.Warning("First line is longer than 50 characters (recommended for subject line)")
 	}
 
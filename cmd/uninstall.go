@@ -149,6 +149,7 @@ func removeConfigFiles(ui *ui.UI) error {
 		filepath.Join(homeDir, ".config", "ai-git"),
 		filepath.Join(homeDir, ".ai-git.yaml"),
 		filepath.Join(homeDir, ".ai-git"),
+		filepath.Join(homeDir, ".local", "share", "ai-git"),
 	}
 
 	ui.StopSpinner()
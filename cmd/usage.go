@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/anans9/ai-git/internal/config"
+	"github.com/anans9/ai-git/internal/ui"
+	"github.com/anans9/ai-git/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	usageDays int
+	usageBy   string
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show AI token usage and estimated cost",
+	Long: `Show the tokens used by AI requests and their estimated cost.
+
+Every request is recorded in ~/.local/share/ai-git/usage.jsonl with the
+repository, command, provider and model. Costs are estimated from a built-in
+price table that can be extended or overridden under ai.usage.prices.
+
+Examples:
+  ai-git usage                           # Totals per day, model and repo
+  ai-git usage --days 7                  # Only the last week
+  ai-git usage --by model                # Only the per-model totals
+  ai-git usage clear                     # Remove the usage ledger`,
+	RunE: runUsage,
+}
+
+var usageClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Clear the usage ledger",
+	Long:  `Remove all recorded token usage.`,
+	RunE:  runUsageClear,
+}
+
+func init() {
+	usageCmd.AddCommand(usageClearCmd)
+
+	usageCmd.Flags().IntVar(&usageDays, "days", 30, "Number of days to include (0 for all)")
+	usageCmd.Flags().StringVar(&usageBy, "by", "", "Group by day, model or repo (default all)")
+	usageClearCmd.Flags().BoolP("force", "f", false, "Clear without confirmation")
+}
+
+func runUsage(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui := ui.NewUI(cfg.UI.Color, cfg.UI.Interactive)
+
+	groupings := map[string]func(usage.Record) string{
+		"day": func(r usage.Record) string {
+			return r.Time.Local().Format("2006-01-02")
+		},
+		"model": func(r usage.Record) string {
+			return fmt.Sprintf("%s/%s", r.Provider, r.Model)
+		},
+		"repo": func(r usage.Record) string {
+			if r.Repo == "" {
+				return "(none)"
+			}
+			return filepath.Base(r.Repo)
+		},
+	}
+
+	order := []string{"day", "model", "repo"}
+	if usageBy != "" {
+		if _, ok := groupings[usageBy]; !ok {
+			return fmt.Errorf("invalid grouping: %s (use day, model or repo)", usageBy)
+		}
+		order = []string{usageBy}
+	}
+
+	var since time.Time
+	if usageDays > 0 {
+		now := time.Now()
+		since = time.Date(now.Year(), now.Month(), now.Day()-usageDays+1, 0, 0, 0, 0, now.Location())
+	}
+
+	ledger := usage.NewLedger(usage.DefaultPath())
+	records, err := ledger.Records(since)
+	if err != nil {
+		ui.Error("Failed to read usage ledger: %v", err)
+		return err
+	}
+
+	if len(records) == 0 {
+		ui.Info("No AI usage recorded")
+		if !cfg.AI.Usage.Enabled {
+			ui.Info("Enable recording with 'ai-git config set ai.usage.enabled true'")
+		}
+		return nil
+	}
+
+	prices := usage.NewPriceTable(cfg.AI.Usage.Prices)
+
+	for _, by := range order {
+		ui.Header(fmt.Sprintf("Usage by %s", by))
+		printUsageTotals(ui, by, usage.Summarize(records, prices, groupings[by]))
+		ui.Print("")
+	}
+
+	total := usage.Total(records, prices)
+	ui.Printf("Total: %d requests, %d tokens (%d prompt, %d completion), %s",
+		total.Requests, total.TotalTokens, total.PromptTokens, total.CompletionTokens, formatCost(total))
+	if total.Unpriced > 0 {
+		ui.Dim("%d requests used models without a price; add them under ai.usage.prices", total.Unpriced)
+	}
+
+	return nil
+}
+
+func printUsageTotals(ui *ui.UI, by string, groups []usage.Totals) {
+	headers := []string{by, "requests", "prompt", "completion", "total", "cost"}
+
+	rows := make([][]string, 0, len(groups))
+	for _, group := range groups {
+		rows = append(rows, []string{
+			group.Key,
+			fmt.Sprintf("%d", group.Requests),
+			fmt.Sprintf("%d", group.PromptTokens),
+			fmt.Sprintf("%d", group.CompletionTokens),
+			fmt.Sprintf("%d", group.TotalTokens),
+			formatCost(group),
+		})
+	}
+
+	ui.PrintTable(headers, rows)
+}
+
+// formatCost formats the estimated cost, marking totals that include
+// requests without a known price
+func formatCost(totals usage.Totals) string {
+	if totals.Unpriced == totals.Requests {
+		return "-"
+	}
+	cost := fmt.Sprintf("$%.4f", totals.Cost)
+	if totals.Unpriced > 0 {
+		cost += "+"
+	}
+	return cost
+}
+
+func runUsageClear(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ui := ui.NewUI(cfg.UI.Color, cfg.UI.Interactive)
+
+	force, _ := cmd.Flags().GetBool("force")
+	if !force {
+		confirmed, err := ui.Confirm("Remove all recorded AI usage?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			ui.Info("Usage clear cancelled")
+			return nil
+		}
+	}
+
+	if err := usage.NewLedger(usage.DefaultPath()).Clear(); err != nil {
+		ui.Error("Failed to clear usage ledger: %v", err)
+		return err
+	}
+
+	ui.Success("Usage ledger cleared")
+	return nil
+}
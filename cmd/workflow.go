@@ -1,10 +1,8 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/anans9/ai-git/internal/ai"
 	"github.com/anans9/ai-git/internal/config"
@@ -227,6 +225,8 @@ func runWorkflowRun(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		ui.Warning("Failed to initialize AI client: %v", err)
 		// Continue without AI for workflows that don't need it
+	} else {
+		aiClient.SetCommand("workflow " + workflowName)
 	}
 
 	// Execute workflow
@@ -613,21 +613,35 @@ func (e *WorkflowExecutor) executeAICommit(step config.WorkflowStep) error {
 		return nil
 	}
 
+	diff = redactDiff(e.ui, e.aiClient, diff)
+	e.aiClient.SetDiff(diff)
+	e.aiClient.SetPromptContext(newPromptContext(e.config, e.gitClient, diff))
+
+	// Format diff for AI
+	diffContent, err := diffForAI(e.config, e.ui, e.aiClient, diff)
+	if err != nil {
+		return err
+	}
+
 	// Generate commit message
 	e.ui.StartSpinner("Generating AI commit message...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := aiContext(e.config, "commit")
 	defer cancel()
 
-	// Format diff for AI
-	diffContent := formatDiffForAI(diff, e.config.Git.MaxDiffLines)
-	message, err := e.aiClient.GenerateCommitMessage(ctx, diffContent)
+	messages, err := generateCandidates(ctx, e.config, e.aiClient, diffContent, 1)
 	if err != nil {
 		e.ui.StopSpinner()
-		return fmt.Errorf("failed to generate commit message: %w", err)
+		return fmt.Errorf("failed to generate commit message: %w", aiError(ctx, e.config, "commit", err))
+	}
+	if len(messages) == 0 {
+		e.ui.StopSpinner()
+		return fmt.Errorf("failed to generate commit message: AI generated empty commit message")
 	}
+	message := messages[0]
 
 	e.ui.StopSpinner()
+	reportFallback(e.config, e.ui, e.aiClient)
 
 	// Store message in context for later steps
 	e.context.Data["commit_message"] = message
@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anans9/ai-git/internal/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// defaultAzureAPIVersion is used when no api_version is configured
+const defaultAzureAPIVersion = "2024-02-01"
+
+// NewAzureProvider creates a provider for Azure OpenAI. Requests are routed
+// to the configured deployment on the resource endpoint and authenticated
+// with the api-key header.
+func NewAzureProvider(cfg *config.Config, name string) (*OpenAIProvider, error) {
+	providerConfig, err := cfg.GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := providerConfig.Endpoint
+	if endpoint == "" {
+		endpoint = providerConfig.BaseURL
+	}
+	if endpoint == "" {
+		return nil, fmt.Errorf("endpoint is required for Azure provider %s", name)
+	}
+	if providerConfig.Deployment == "" {
+		return nil, fmt.Errorf("deployment is required for Azure provider %s", name)
+	}
+	if providerConfig.APIKey == "" {
+		return nil, fmt.Errorf("Azure API key is required")
+	}
+
+	httpClient, err := newHTTPClient(cfg, name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConfig := openai.DefaultAzureConfig(providerConfig.APIKey, strings.TrimSuffix(endpoint, "/"))
+	clientConfig.HTTPClient = httpClient
+	clientConfig.APIVersion = providerConfig.APIVersion
+	if clientConfig.APIVersion == "" {
+		clientConfig.APIVersion = defaultAzureAPIVersion
+	}
+
+	// Every request goes to the configured deployment, whatever the model
+	deployment := providerConfig.Deployment
+	clientConfig.AzureModelMapperFunc = func(string) string {
+		return deployment
+	}
+
+	// The model only names the deployment's model for usage reporting
+	model := providerConfig.Model
+	if model == "" {
+		model = deployment
+	}
+
+	return &OpenAIProvider{
+		name:   name,
+		label:  "Azure OpenAI",
+		model:  model,
+		client: openai.NewClientWithConfig(clientConfig),
+		config: cfg,
+	}, nil
+}
@@ -4,52 +4,113 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/anans9/ai-git/internal/cache"
 	"github.com/anans9/ai-git/internal/config"
+	"github.com/anans9/ai-git/internal/git"
+	"github.com/anans9/ai-git/internal/prompt"
+	"github.com/anans9/ai-git/internal/redact"
+	"github.com/anans9/ai-git/internal/usage"
 	"github.com/sashabaranov/go-openai"
 )
 
 // Client represents an AI client that can work with multiple providers
 type Client struct {
-	config   *config.Config
-	provider Provider
-	client   *http.Client
+	config         *config.Config
+	mu             sync.Mutex
+	provider       Provider
+	providers      []Provider
+	fallbackErrors []error
+	providerErrors []error
+	cache          *cache.Cache
+	refresh        bool
+	ledger         *usage.Ledger
+	command        string
+	repo           string
+	promptContext  prompt.Context
+	diff           *git.Diff
+	redactor       *redact.Redactor
 }
 
 // Provider defines the interface for AI providers
 type Provider interface {
-	GenerateCommitMessage(ctx context.Context, diff string) (string, error)
-	GeneratePRTitle(ctx context.Context, changes string) (string, error)
-	GeneratePRDescription(ctx context.Context, changes string) (string, error)
+	// Complete sends a request and returns the generated text
+	Complete(ctx context.Context, req Request) (Response, error)
+	// Stream sends a request, passing each token to onToken as it arrives
+	Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error)
 	Name() string
 }
 
+// TokenHandler receives chunks of generated text as they are streamed
+type TokenHandler func(token string)
+
 // Request represents a generic AI request
 type Request struct {
 	Prompt       string
 	SystemPrompt string
 	MaxTokens    int
 	Temperature  float64
+	// N asks for several alternative completions, returned in Response.Choices
+	N int
+	// Schema is the JSON schema of an object to return instead of free text.
+	// Providers enforce it with JSON mode or a tool call where the model
+	// supports one; the prompt must still ask for JSON.
+	Schema json.RawMessage
+	// Diff is the diff described by the prompt, for providers that work
+	// from the changes rather than the prompt. It is not sent to models.
+	Diff *git.Diff
+	// Kind names what the request asks for, such as KindCommit, for the
+	// same providers
+	Kind RequestKind
+	// ReplayPrompt is the prompt rendered without the branch, ticket and
+	// recent commits, which differ between checkouts of the same change.
+	// Recorded responses are matched on it when set. It is not sent to
+	// models.
+	ReplayPrompt string
 }
 
+// RequestKind names what a request asks for
+type RequestKind string
+
+// Kinds of requests
+const (
+	KindCommit         RequestKind = "commit"
+	KindSummary        RequestKind = "summary"
+	KindReview         RequestKind = "review"
+	KindPRTitle        RequestKind = "pr-title"
+	KindPRDescription  RequestKind = "pr-description"
+	KindConnectionTest RequestKind = "connection-test"
+)
+
 // Response represents a generic AI response
 type Response struct {
 	Content string
+	Choices []string
 	Usage   Usage
 }
 
 // Usage represents token usage information
 type Usage struct {
-	PromptTokens     int
-	CompletionTokens int
-	TotalTokens      int
+	PromptTokens     int `yaml:"prompt_tokens"`
+	CompletionTokens int `yaml:"completion_tokens"`
+	TotalTokens      int `yaml:"total_tokens"`
 }
 
+// languageInstructions asks for generated text in another language. Commit
+// types and scopes are parsed by tools, so they stay as they are.
+const languageInstructions = `
+
+Write commit messages and pull request titles and descriptions in %s, following its grammar and capitalization.
+Keep conventional commit types such as feat and fix, scopes, code identifiers and JSON field names in English.`
+
 // NewClient creates a new AI client with the specified configuration
 func NewClient(cfg *config.Config) (*Client, error) {
 	if err := cfg.Validate(); err != nil {
@@ -58,67 +119,430 @@ func NewClient(cfg *config.Config) (*Client, error) {
 
 	client := &Client{
 		config: cfg,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
 	}
 
-	// Initialize the appropriate provider
-	switch cfg.AI.Provider {
-	case "openai":
-		provider, err := NewOpenAIProvider(cfg)
+	// Initialize the appropriate provider, followed by the fallback chain.
+	// A provider that cannot be created, for example for lack of an API key,
+	// leaves the fallback providers to answer, or the heuristic provider
+	// when there are none and it is enabled.
+	fallbacks := newFallbackProviders(cfg)
+	provider, err := NewProvider(cfg, cfg.AI.Provider)
+	if err == nil {
+		client.provider = provider
+		client.providers = append([]Provider{provider}, fallbacks...)
+	} else {
+		if len(fallbacks) == 0 {
+			heuristic, ok := lastResortProvider(cfg)
+			if !ok {
+				return nil, err
+			}
+			fallbacks = []Provider{heuristic}
+		}
+		client.provider = fallbacks[0]
+		client.providers = fallbacks
+		client.providerErrors = []error{&FallbackError{Provider: cfg.AI.Provider, Err: err}}
+	}
+
+	if cfg.AI.Record != "" {
+		// Every request must reach a provider to be recorded
+		recording, err := newRecordingProviders(cfg, cfg.AI.Record, client.providers)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create OpenAI provider: %w", err)
+			return nil, err
 		}
-		client.provider = provider
-	case "anthropic":
-		provider, err := NewAnthropicProvider(cfg)
+		client.provider = recording[0]
+		client.providers = recording
+		client.refresh = true
+	}
+
+	if cfg.AI.Cache.Enabled {
+		responseCache, err := cache.NewFromConfig(cfg.AI.Cache)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create Anthropic provider: %w", err)
+			return nil, err
 		}
-		client.provider = provider
-	case "local":
-		provider, err := NewLocalProvider(cfg)
+		client.cache = responseCache
+	}
+
+	if cfg.AI.Redact.Enabled {
+		redactor, err := redact.New(cfg.AI.Redact)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create local provider: %w", err)
+			return nil, err
+		}
+		client.redactor = redactor
+	}
+
+	if cfg.AI.Usage.Enabled {
+		client.ledger = usage.NewLedger(usage.DefaultPath())
+		if dir, err := os.Getwd(); err == nil {
+			client.repo = usage.FindRepo(dir)
 		}
-		client.provider = provider
-	default:
-		return nil, fmt.Errorf("unsupported AI provider: %s", cfg.AI.Provider)
 	}
 
 	return client, nil
 }
 
+// NewRequest creates a request for prompt using the configured system
+// prompt, language, max tokens and temperature
+func (c *Client) NewRequest(prompt string) Request {
+	return Request{
+		Prompt:       prompt,
+		SystemPrompt: c.systemPrompt(),
+		MaxTokens:    c.config.AI.MaxTokens,
+		Temperature:  c.config.AI.Temperature,
+		Diff:         c.diff,
+	}
+}
+
+// Complete sends a request through the fallback chain, reusing a cached
+// response for identical requests. Suspected secrets in the prompts are
+// redacted first.
+func (c *Client) Complete(ctx context.Context, req Request) (Response, error) {
+	req = c.redactRequest(req)
+
+	var resp Response
+	if c.lookup(req, &resp) {
+		return resp, nil
+	}
+
+	provider, err := c.withFallback(ctx, func(ctx context.Context, provider Provider) error {
+		var err error
+		resp, err = provider.Complete(ctx, req)
+		return err
+	})
+	if err != nil {
+		return Response{}, err
+	}
+
+	c.store(provider, req, resp)
+	return resp, nil
+}
+
+// Stream is like Complete but passes each token to onToken as it arrives.
+// A cached response is passed to onToken in one piece.
+func (c *Client) Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error) {
+	req = c.redactRequest(req)
+
+	var resp Response
+	if c.lookup(req, &resp) {
+		if onToken != nil {
+			onToken(resp.Content)
+		}
+		return resp, nil
+	}
+
+	provider, err := c.withFallback(ctx, func(ctx context.Context, provider Provider) error {
+		streamed := false
+		var err error
+		resp, err = provider.Stream(ctx, req, func(token string) {
+			streamed = true
+			if onToken != nil {
+				onToken(token)
+			}
+		})
+		if err != nil && streamed {
+			// Tokens were already shown, so another provider cannot take
+			// over; drop the error chain so the failure is not retried
+			return fmt.Errorf("%s stream interrupted: %v", provider.Name(), err)
+		}
+		return err
+	})
+	if err != nil {
+		return Response{}, err
+	}
+
+	c.store(provider, req, resp)
+	return resp, nil
+}
+
 // GenerateCommitMessage generates a commit message based on the git diff
 func (c *Client) GenerateCommitMessage(ctx context.Context, diff string) (string, error) {
-	return c.provider.GenerateCommitMessage(ctx, diff)
+	commitPrompt, replayPrompt, err := c.commitPrompt(diff)
+	if err != nil {
+		return "", err
+	}
+	req := c.NewRequest(commitPrompt)
+	req.Kind = KindCommit
+	req.ReplayPrompt = replayPrompt
+	return text(c.Complete(ctx, req))
+}
+
+// GenerateCommitMessages generates up to n distinct commit message candidates
+func (c *Client) GenerateCommitMessages(ctx context.Context, diff string, n int) ([]string, error) {
+	commitPrompt, replayPrompt, err := c.commitPrompt(diff)
+	if err != nil {
+		return nil, err
+	}
+	req := c.NewRequest(commitPrompt)
+	req.Kind = KindCommit
+	req.ReplayPrompt = replayPrompt
+	req.N = n
+
+	resp, err := c.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Choices) == 0 {
+		return []string{resp.Content}, nil
+	}
+	return resp.Choices, nil
+}
+
+// StreamCommitMessage generates a commit message, passing each token to
+// onToken as it arrives. The complete message is returned when done.
+func (c *Client) StreamCommitMessage(ctx context.Context, diff string, onToken TokenHandler) (string, error) {
+	commitPrompt, replayPrompt, err := c.commitPrompt(diff)
+	if err != nil {
+		return "", err
+	}
+	req := c.NewRequest(commitPrompt)
+	req.Kind = KindCommit
+	req.ReplayPrompt = replayPrompt
+	return text(c.Stream(ctx, req, onToken))
 }
 
 // GeneratePRTitle generates a pull request title
 func (c *Client) GeneratePRTitle(ctx context.Context, changes string) (string, error) {
-	return c.provider.GeneratePRTitle(ctx, changes)
+	titlePrompt, replayPrompt, err := c.renderPrompt(c.config.Templates.Prompts.PRTitle, changes)
+	if err != nil {
+		return "", err
+	}
+	req := c.NewRequest(titlePrompt)
+	req.ReplayPrompt = replayPrompt
+	req.Kind = KindPRTitle
+	return text(c.Complete(ctx, req))
 }
 
 // GeneratePRDescription generates a pull request description
 func (c *Client) GeneratePRDescription(ctx context.Context, changes string) (string, error) {
-	return c.provider.GeneratePRDescription(ctx, changes)
+	descriptionPrompt, replayPrompt, err := c.renderPrompt(c.config.Templates.Prompts.PRDescription, changes)
+	if err != nil {
+		return "", err
+	}
+	req := c.NewRequest(descriptionPrompt)
+	req.ReplayPrompt = replayPrompt
+	req.Kind = KindPRDescription
+	return text(c.Complete(ctx, req))
+}
+
+// commitPrompt renders the commit prompt for diff, and the same prompt for
+// matching recorded responses as renderPrompt does
+func (c *Client) commitPrompt(diff string) (string, string, error) {
+	tmpl := c.config.Templates.Prompts.CommitMessage
+	rendered, replay, err := c.renderPrompt(tmpl, diff)
+	if err != nil {
+		return "", "", err
+	}
+	examples := c.promptContext.Examples
+	return withExamples(rendered, tmpl, examples), withExamples(replay, tmpl, examples), nil
+}
+
+// Redact replaces suspected secrets in text with placeholders and returns
+// the kinds of secrets found. Text is returned unchanged when redaction is
+// disabled.
+func (c *Client) Redact(text string) (string, []string) {
+	if c.redactor == nil {
+		return text, nil
+	}
+	return c.redactor.Redact(text)
+}
+
+// redactRequest redacts the prompts of a request before it is cached or sent
+// to a provider
+func (c *Client) redactRequest(req Request) Request {
+	req.Prompt, _ = c.Redact(req.Prompt)
+	req.SystemPrompt, _ = c.Redact(req.SystemPrompt)
+	req.ReplayPrompt, _ = c.Redact(req.ReplayPrompt)
+	return req
+}
+
+// SetPromptContext sets the repository context available to prompt
+// templates. The diff is filled in for each prompt.
+func (c *Client) SetPromptContext(data prompt.Context) {
+	c.promptContext = data
+}
+
+// SetDiff sets the diff that later requests describe
+func (c *Client) SetDiff(diff *git.Diff) {
+	c.diff = diff
+}
+
+// renderPrompt renders a prompt template with the prompt context and diff.
+// It also renders the template without the branch, ticket and recent
+// commits, so that responses recorded on one checkout of a change are found
+// on another, such as a detached CI checkout.
+func (c *Client) renderPrompt(tmpl, diff string) (string, string, error) {
+	data := c.promptContext
+	data.Diff = diff
+	data.Language = prompt.LanguageName(c.config.AI.Language)
+	rendered, err := prompt.Render(tmpl, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	data.Branch, data.Ticket, data.RecentCommits = "", "", nil
+	replay, err := prompt.Render(tmpl, data)
+	if err != nil {
+		return "", "", err
+	}
+	return rendered, replay, nil
+}
+
+// systemPrompt returns the configured system prompt, asking for text in the
+// configured language
+func (c *Client) systemPrompt() string {
+	language := prompt.LanguageName(c.config.AI.Language)
+	if language == "" {
+		return c.config.AI.SystemPrompt
+	}
+	return c.config.AI.SystemPrompt + fmt.Sprintf(languageInstructions, language)
+}
+
+// SetCommand sets the command recorded in the usage ledger
+func (c *Client) SetCommand(command string) {
+	c.command = command
+}
+
+// logUsage appends the usage collected by recorder to the ledger.
+// Recording is best effort and never fails a request.
+func (c *Client) logUsage(provider Provider, recorder *usageRecorder) {
+	if c.ledger == nil || !recorder.reported {
+		return
+	}
+
+	// Chunks of a diff are summarized concurrently, so keep their records
+	// from interleaving
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.ledger.Append(usage.Record{
+		Time:             time.Now(),
+		Repo:             c.repo,
+		Command:          c.command,
+		Provider:         provider.Name(),
+		Model:            recorder.model,
+		PromptTokens:     recorder.usage.PromptTokens,
+		CompletionTokens: recorder.usage.CompletionTokens,
+		TotalTokens:      recorder.usage.TotalTokens,
+		Estimated:        recorder.estimated,
+	})
+}
+
+// RefreshCache makes later requests skip cached responses. Fresh responses
+// still replace the cached ones.
+func (c *Client) RefreshCache() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh = true
+}
+
+// cacheKey identifies a response by provider, model and request
+func (c *Client) cacheKey(provider Provider, req Request) string {
+	providerModel := ""
+	if providerConfig, err := c.config.GetProvider(provider.Name()); err == nil {
+		providerModel = providerConfig.Model
+	}
+
+	parts := []string{
+		provider.Name(),
+		c.config.ModelFor(provider.Name()),
+		providerModel,
+		req.SystemPrompt,
+		req.Prompt,
+		fmt.Sprintf("%d %g %d", req.MaxTokens, req.Temperature, req.N),
+	}
+	if len(req.Schema) > 0 {
+		parts = append(parts, string(req.Schema))
+	}
+
+	return cache.Key(parts...)
+}
+
+// lookup loads a cached response, preferring providers earlier in the
+// fallback chain. The provider whose response was found becomes current.
+func (c *Client) lookup(req Request, v interface{}) bool {
+	c.mu.Lock()
+	refresh := c.refresh
+	c.mu.Unlock()
+	if c.cache == nil || refresh {
+		return false
+	}
+
+	for _, provider := range c.providers {
+		if c.cache.Get(c.cacheKey(provider, req), v) {
+			c.setProvider(provider, nil)
+			return true
+		}
+	}
+	return false
 }
 
-// GetProviderName returns the name of the current provider
+// store caches a response under the provider that produced it
+func (c *Client) store(provider Provider, req Request, v interface{}) {
+	if c.cache == nil {
+		return
+	}
+	// Caching is best effort; a failed write only costs a future request
+	_ = c.cache.Set(c.cacheKey(provider, req), v)
+}
+
+// setProvider records the provider that produced the last response and the
+// failures of the providers skipped before it. A nil provider keeps the
+// current one.
+func (c *Client) setProvider(provider Provider, fallbackErrors []error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if provider != nil {
+		c.provider = provider
+	}
+	c.fallbackErrors = fallbackErrors
+}
+
+// GetProviderName returns the name of the provider that produced the last
+// response, or the configured provider before any request
 func (c *Client) GetProviderName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.provider.Name()
 }
 
+// HeuristicResponse reports whether the last response came from the
+// heuristic provider, which writes English whatever the configured language
+func (c *Client) HeuristicResponse() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	provider := c.provider
+	if recording, ok := provider.(*recordingProvider); ok {
+		provider = recording.Provider
+	}
+	_, ok := provider.(*HeuristicProvider)
+	return ok
+}
+
+// FallbackErrors returns the failures of providers that were skipped while
+// producing the last response, including the configured provider when it
+// could not be created
+func (c *Client) FallbackErrors() []error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append(append([]error{}, c.providerErrors...), c.fallbackErrors...)
+}
+
 // OpenAIProvider implements the Provider interface for OpenAI
 type OpenAIProvider struct {
+	name   string
+	label  string
+	model  string
 	client *openai.Client
 	config *config.Config
 }
 
 // NewOpenAIProvider creates a new OpenAI provider
 func NewOpenAIProvider(cfg *config.Config) (*OpenAIProvider, error) {
-	providerConfig, err := cfg.GetProvider("openai")
+	return newOpenAIProvider(cfg, "openai")
+}
+
+func newOpenAIProvider(cfg *config.Config, name string) (*OpenAIProvider, error) {
+	providerConfig, err := cfg.GetProvider(name)
 	if err != nil {
 		return nil, err
 	}
@@ -127,67 +551,150 @@ func NewOpenAIProvider(cfg *config.Config) (*OpenAIProvider, error) {
 		return nil, fmt.Errorf("OpenAI API key is required")
 	}
 
+	httpClient, err := newHTTPClient(cfg, name, 0)
+	if err != nil {
+		return nil, err
+	}
+
 	clientConfig := openai.DefaultConfig(providerConfig.APIKey)
+	clientConfig.HTTPClient = httpClient
 	if providerConfig.BaseURL != "" {
 		clientConfig.BaseURL = providerConfig.BaseURL
 	}
 
 	return &OpenAIProvider{
+		name:   name,
+		label:  "OpenAI",
+		model:  cfg.ModelFor(name),
 		client: openai.NewClientWithConfig(clientConfig),
 		config: cfg,
 	}, nil
 }
 
-func (p *OpenAIProvider) GenerateCommitMessage(ctx context.Context, diff string) (string, error) {
-	prompt := strings.ReplaceAll(p.config.Templates.Prompts.CommitMessage, "{diff}", diff)
-	return p.generate(ctx, prompt)
-}
+// Complete requests a chat completion. Alternative completions are
+// requested in a single call using the n parameter.
+func (p *OpenAIProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	chatReq := p.newRequest(req)
+	if req.N > 1 {
+		chatReq.N = req.N
+	}
 
-func (p *OpenAIProvider) GeneratePRTitle(ctx context.Context, changes string) (string, error) {
-	prompt := strings.ReplaceAll(p.config.Templates.Prompts.PRTitle, "{changes}", changes)
-	return p.generate(ctx, prompt)
-}
+	resp, err := p.client.CreateChatCompletion(ctx, chatReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("%s API error: %w", p.label, err)
+	}
 
-func (p *OpenAIProvider) GeneratePRDescription(ctx context.Context, changes string) (string, error) {
-	prompt := strings.ReplaceAll(p.config.Templates.Prompts.PRDescription, "{changes}", changes)
-	return p.generate(ctx, prompt)
+	usage := openAIUsage(resp.Usage)
+	recordUsage(ctx, chatReq.Model, usage)
+
+	choices := make([]string, 0, len(resp.Choices))
+	for _, choice := range resp.Choices {
+		choices = append(choices, strings.TrimSpace(choice.Message.Content))
+	}
+
+	choices = uniqueNonEmpty(choices)
+	if len(choices) == 0 {
+		return Response{}, fmt.Errorf("no response from %s", p.label)
+	}
+
+	return Response{
+		Content: choices[0],
+		Choices: choices,
+		Usage:   usage,
+	}, nil
 }
 
 func (p *OpenAIProvider) Name() string {
-	return "openai"
+	return p.name
 }
 
-func (p *OpenAIProvider) generate(ctx context.Context, prompt string) (string, error) {
-	req := openai.ChatCompletionRequest{
-		Model:       p.config.AI.Model,
-		Temperature: float32(p.config.AI.Temperature),
-		MaxTokens:   p.config.AI.MaxTokens,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: p.config.AI.SystemPrompt,
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
+// Model returns the model sent with requests
+func (p *OpenAIProvider) Model() string {
+	return p.model
+}
+
+func (p *OpenAIProvider) newRequest(req Request) openai.ChatCompletionRequest {
+	messages := []openai.ChatCompletionMessage{}
+	if req.SystemPrompt != "" {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: req.SystemPrompt,
+		})
+	}
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: req.Prompt,
+	})
+
+	chatReq := openai.ChatCompletionRequest{
+		Model:       p.model,
+		Temperature: float32(req.Temperature),
+		MaxTokens:   req.MaxTokens,
+		Messages:    messages,
+	}
+	if len(req.Schema) > 0 && supportsJSONOutput(p.config, p.model) {
+		chatReq.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
 	}
 
-	resp, err := p.client.CreateChatCompletion(ctx, req)
+	return chatReq
+}
+
+// Stream requests a streamed chat completion
+func (p *OpenAIProvider) Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error) {
+	chatReq := p.newRequest(req)
+	chatReq.Stream = true
+
+	stream, err := p.client.CreateChatCompletionStream(ctx, chatReq)
 	if err != nil {
-		return "", fmt.Errorf("OpenAI API error: %w", err)
+		return Response{}, fmt.Errorf("%s API error: %w", p.label, err)
 	}
+	defer stream.Close()
 
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no response from OpenAI")
+	var content strings.Builder
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Response{}, fmt.Errorf("%s stream error: %w", p.label, err)
+		}
+
+		if len(resp.Choices) == 0 || resp.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		token := resp.Choices[0].Delta.Content
+		content.WriteString(token)
+		if onToken != nil {
+			onToken(token)
+		}
 	}
 
-	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+	if content.Len() == 0 {
+		return Response{}, fmt.Errorf("no response from %s", p.label)
+	}
+
+	// Streamed responses do not report usage, so estimate it
+	recordEstimatedUsage(ctx, p.config, chatReq.Model, req.SystemPrompt+req.Prompt, content.String())
+
+	return Response{Content: strings.TrimSpace(content.String())}, nil
+}
+
+// openAIUsage converts usage reported by the OpenAI API
+func openAIUsage(usage openai.Usage) Usage {
+	return Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
 }
 
 // AnthropicProvider implements the Provider interface for Anthropic Claude
 type AnthropicProvider struct {
+	name   string
 	apiKey string
 	config *config.Config
 	client *http.Client
@@ -200,8 +707,27 @@ type AnthropicRequest struct {
 	Temperature float64            `json:"temperature"`
 	Messages    []AnthropicMessage `json:"messages"`
 	System      string             `json:"system,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+	Tools       []AnthropicTool    `json:"tools,omitempty"`
+	ToolChoice  *AnthropicToolUse  `json:"tool_choice,omitempty"`
+}
+
+// AnthropicTool describes a tool the model may call
+type AnthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
 }
 
+// AnthropicToolUse forces the model to call the named tool
+type AnthropicToolUse struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// anthropicOutputTool is the tool used to receive structured output
+const anthropicOutputTool = "respond"
+
 // AnthropicMessage represents a message in the Anthropic API
 type AnthropicMessage struct {
 	Role    string `json:"role"`
@@ -218,6 +744,11 @@ type AnthropicResponse struct {
 type AnthropicContent struct {
 	Type string `json:"type"`
 	Text string `json:"text"`
+	// Name and Input hold the tool and arguments of a tool_use block
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// PartialJSON holds a chunk of tool arguments in a streamed delta
+	PartialJSON string `json:"partial_json,omitempty"`
 }
 
 // AnthropicUsage represents usage information from Anthropic
@@ -226,9 +757,50 @@ type AnthropicUsage struct {
 	OutputTokens int `json:"output_tokens"`
 }
 
+// text returns the text of the response, or the arguments of the output
+// tool call when structured output was requested
+func (r AnthropicResponse) text() string {
+	for _, block := range r.Content {
+		if block.Type == "tool_use" && block.Name == anthropicOutputTool {
+			return string(block.Input)
+		}
+	}
+	if len(r.Content) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(r.Content[0].Text)
+}
+
+func (u AnthropicUsage) toUsage() Usage {
+	return Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+// AnthropicStreamEvent represents a server-sent event from the Anthropic streaming API
+type AnthropicStreamEvent struct {
+	Type    string             `json:"type"`
+	Delta   AnthropicContent   `json:"delta"`
+	Message *AnthropicResponse `json:"message,omitempty"`
+	Usage   *AnthropicUsage    `json:"usage,omitempty"`
+	Error   *AnthropicError    `json:"error,omitempty"`
+}
+
+// AnthropicError represents an error returned by the Anthropic API
+type AnthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
 // NewAnthropicProvider creates a new Anthropic provider
 func NewAnthropicProvider(cfg *config.Config) (*AnthropicProvider, error) {
-	providerConfig, err := cfg.GetProvider("anthropic")
+	return newAnthropicProvider(cfg, "anthropic")
+}
+
+func newAnthropicProvider(cfg *config.Config, name string) (*AnthropicProvider, error) {
+	providerConfig, err := cfg.GetProvider(name)
 	if err != nil {
 		return nil, err
 	}
@@ -237,219 +809,272 @@ func NewAnthropicProvider(cfg *config.Config) (*AnthropicProvider, error) {
 		return nil, fmt.Errorf("Anthropic API key is required")
 	}
 
+	httpClient, err := newHTTPClient(cfg, name, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
 	return &AnthropicProvider{
+		name:   name,
 		apiKey: providerConfig.APIKey,
 		config: cfg,
-		client: &http.Client{Timeout: 30 * time.Second},
+		client: httpClient,
 	}, nil
 }
 
-func (p *AnthropicProvider) GenerateCommitMessage(ctx context.Context, diff string) (string, error) {
-	prompt := strings.ReplaceAll(p.config.Templates.Prompts.CommitMessage, "{diff}", diff)
-	return p.generate(ctx, prompt)
-}
-
-func (p *AnthropicProvider) GeneratePRTitle(ctx context.Context, changes string) (string, error) {
-	prompt := strings.ReplaceAll(p.config.Templates.Prompts.PRTitle, "{changes}", changes)
-	return p.generate(ctx, prompt)
+// Complete sends a message request. Alternative completions are requested
+// concurrently since the API returns a single completion per call.
+func (p *AnthropicProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	return completeParallel(ctx, req, p.complete)
 }
 
-func (p *AnthropicProvider) GeneratePRDescription(ctx context.Context, changes string) (string, error) {
-	prompt := strings.ReplaceAll(p.config.Templates.Prompts.PRDescription, "{changes}", changes)
-	return p.generate(ctx, prompt)
+func (p *AnthropicProvider) Name() string {
+	return p.name
 }
 
-func (p *AnthropicProvider) Name() string {
-	return "anthropic"
+// Model returns the model sent with requests
+func (p *AnthropicProvider) Model() string {
+	return p.config.ModelFor(p.Name())
 }
 
-func (p *AnthropicProvider) generate(ctx context.Context, prompt string) (string, error) {
-	req := AnthropicRequest{
-		Model:       p.config.AI.Model,
-		MaxTokens:   p.config.AI.MaxTokens,
-		Temperature: p.config.AI.Temperature,
-		System:      p.config.AI.SystemPrompt,
+func (p *AnthropicProvider) newRequest(ctx context.Context, req Request, stream bool) (*http.Request, error) {
+	anthropicReq := AnthropicRequest{
+		Model:       p.config.ModelFor(p.Name()),
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		System:      req.SystemPrompt,
+		Stream:      stream,
 		Messages: []AnthropicMessage{
 			{
 				Role:    "user",
-				Content: prompt,
+				Content: req.Prompt,
 			},
 		},
 	}
+	if len(req.Schema) > 0 && supportsJSONOutput(p.config, anthropicReq.Model) {
+		// A forced tool call makes the model answer with arguments that
+		// match the schema
+		anthropicReq.Tools = []AnthropicTool{{
+			Name:        anthropicOutputTool,
+			Description: "Respond with the requested object",
+			InputSchema: req.Schema,
+		}}
+		anthropicReq.ToolChoice = &AnthropicToolUse{Type: "tool", Name: anthropicOutputTool}
+	}
 
-	jsonData, err := json.Marshal(req)
+	jsonData, err := json.Marshal(anthropicReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("x-api-key", p.apiKey)
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
 
+	return httpReq, nil
+}
+
+func (p *AnthropicProvider) complete(ctx context.Context, req Request) (Response, error) {
+	httpReq, err := p.newRequest(ctx, req, false)
+	if err != nil {
+		return Response{}, err
+	}
+
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("Anthropic API request failed: %w", err)
+		return Response{}, fmt.Errorf("Anthropic API request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return Response{}, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Anthropic API error (status %d): %s", resp.StatusCode, string(body))
+		return Response{}, &StatusError{Provider: "Anthropic", StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var anthropicResp AnthropicResponse
 	if err := json.Unmarshal(body, &anthropicResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		return Response{}, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	if len(anthropicResp.Content) == 0 {
-		return "", fmt.Errorf("no content in Anthropic response")
+	usage := anthropicResp.Usage.toUsage()
+	recordUsage(ctx, p.config.ModelFor(p.Name()), usage)
+
+	content := anthropicResp.text()
+	if content == "" {
+		return Response{}, fmt.Errorf("no content in Anthropic response")
 	}
 
-	return strings.TrimSpace(anthropicResp.Content[0].Text), nil
+	return Response{
+		Content: content,
+		Usage:   usage,
+	}, nil
 }
 
-// LocalProvider implements the Provider interface for local models (e.g., Ollama)
-type LocalProvider struct {
-	baseURL string
-	model   string
-	config  *config.Config
-	client  *http.Client
-}
+// Stream sends a message request and reads the server-sent events
+func (p *AnthropicProvider) Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error) {
+	httpReq, err := p.newRequest(ctx, req, true)
+	if err != nil {
+		return Response{}, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
 
-// LocalRequest represents a request to a local AI model
-type LocalRequest struct {
-	Model   string       `json:"model"`
-	Prompt  string       `json:"prompt"`
-	Stream  bool         `json:"stream"`
-	Options LocalOptions `json:"options,omitempty"`
-}
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("Anthropic API request failed: %w", err)
+	}
+	defer resp.Body.Close()
 
-// LocalOptions represents options for local AI models
-type LocalOptions struct {
-	Temperature float64 `json:"temperature,omitempty"`
-	NumPredict  int     `json:"num_predict,omitempty"`
-}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return Response{}, &StatusError{Provider: "Anthropic", StatusCode: resp.StatusCode, Body: string(body)}
+	}
 
-// LocalResponse represents a response from a local AI model
-type LocalResponse struct {
-	Response string `json:"response"`
-	Done     bool   `json:"done"`
-}
+	var content strings.Builder
+	var usage AnthropicUsage
+	err = readSSE(resp.Body, func(data string) error {
+		var event AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
 
-// NewLocalProvider creates a new local provider
-func NewLocalProvider(cfg *config.Config) (*LocalProvider, error) {
-	providerConfig, err := cfg.GetProvider("local")
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				usage.InputTokens = event.Message.Usage.InputTokens
+				usage.OutputTokens = event.Message.Usage.OutputTokens
+			}
+		case "message_delta":
+			// The output token count is cumulative
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "content_block_delta":
+			token := event.Delta.Text + event.Delta.PartialJSON
+			if token == "" {
+				return nil
+			}
+			content.WriteString(token)
+			if onToken != nil {
+				onToken(token)
+			}
+		case "error":
+			if event.Error != nil {
+				return fmt.Errorf("Anthropic stream error (%s): %s", event.Error.Type, event.Error.Message)
+			}
+			return fmt.Errorf("Anthropic stream error")
+		case "message_stop":
+			return errStreamDone
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return Response{}, err
 	}
 
-	baseURL := providerConfig.BaseURL
-	if baseURL == "" {
-		baseURL = "http://localhost:11434"
+	recordUsage(ctx, p.config.ModelFor(p.Name()), usage.toUsage())
+
+	if content.Len() == 0 {
+		return Response{}, fmt.Errorf("no content in Anthropic response")
 	}
 
-	return &LocalProvider{
-		baseURL: baseURL,
-		model:   providerConfig.Model,
-		config:  cfg,
-		client:  &http.Client{Timeout: 60 * time.Second},
+	return Response{
+		Content: strings.TrimSpace(content.String()),
+		Usage:   usage.toUsage(),
 	}, nil
 }
 
-func (p *LocalProvider) GenerateCommitMessage(ctx context.Context, diff string) (string, error) {
-	prompt := fmt.Sprintf("%s\n\n%s", p.config.AI.SystemPrompt,
-		strings.ReplaceAll(p.config.Templates.Prompts.CommitMessage, "{diff}", diff))
-	return p.generate(ctx, prompt)
-}
-
-func (p *LocalProvider) GeneratePRTitle(ctx context.Context, changes string) (string, error) {
-	prompt := fmt.Sprintf("%s\n\n%s", p.config.AI.SystemPrompt,
-		strings.ReplaceAll(p.config.Templates.Prompts.PRTitle, "{changes}", changes))
-	return p.generate(ctx, prompt)
-}
-
-func (p *LocalProvider) GeneratePRDescription(ctx context.Context, changes string) (string, error) {
-	prompt := fmt.Sprintf("%s\n\n%s", p.config.AI.SystemPrompt,
-		strings.ReplaceAll(p.config.Templates.Prompts.PRDescription, "{changes}", changes))
-	return p.generate(ctx, prompt)
-}
-
-func (p *LocalProvider) Name() string {
-	return "local"
+// text returns the content of a response
+func text(resp Response, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
 }
 
-func (p *LocalProvider) generate(ctx context.Context, prompt string) (string, error) {
-	req := LocalRequest{
-		Model:  p.model,
-		Prompt: prompt,
-		Stream: false,
-		Options: LocalOptions{
-			Temperature: p.config.AI.Temperature,
-			NumPredict:  p.config.AI.MaxTokens,
-		},
+// completeParallel serves a request for several alternative completions
+// by calling complete concurrently, for providers that return a single
+// completion per call. Failed calls are dropped as long as one succeeds.
+func completeParallel(ctx context.Context, req Request, complete func(ctx context.Context, req Request) (Response, error)) (Response, error) {
+	n := req.N
+	if n <= 1 {
+		return complete(ctx, req)
 	}
-
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+	req.N = 1
+
+	results := make([]Response, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = complete(ctx, req)
+		}(i)
 	}
+	wg.Wait()
 
-	url := fmt.Sprintf("%s/api/generate", p.baseURL)
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+	var succeeded []string
+	var usage Usage
+	for i, result := range results {
+		if errs[i] != nil {
+			continue
+		}
+		succeeded = append(succeeded, result.Content)
+		usage.PromptTokens += result.Usage.PromptTokens
+		usage.CompletionTokens += result.Usage.CompletionTokens
+		usage.TotalTokens += result.Usage.TotalTokens
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := p.client.Do(httpReq)
-	if err != nil {
-		return "", fmt.Errorf("local AI API request failed: %w", err)
+	if len(succeeded) == 0 {
+		return Response{}, errs[0]
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+	choices := uniqueNonEmpty(succeeded)
+	if len(choices) == 0 {
+		return Response{}, fmt.Errorf("AI generated only empty responses")
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("local AI API error (status %d): %s", resp.StatusCode, string(body))
-	}
+	return Response{
+		Content: choices[0],
+		Choices: choices,
+		Usage:   usage,
+	}, nil
+}
 
-	var localResp LocalResponse
-	if err := json.Unmarshal(body, &localResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+// uniqueNonEmpty removes empty and duplicate entries while keeping order
+func uniqueNonEmpty(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		result = append(result, item)
 	}
-
-	return strings.TrimSpace(localResp.Response), nil
+	return result
 }
 
 // TestConnection tests the connection to the AI provider
 func (c *Client) TestConnection(ctx context.Context) error {
-	testPrompt := "Hello, please respond with 'OK' to confirm the connection is working."
-
-	switch c.config.AI.Provider {
-	case "openai":
-		_, err := c.provider.(*OpenAIProvider).generate(ctx, testPrompt)
-		return err
-	case "anthropic":
-		_, err := c.provider.(*AnthropicProvider).generate(ctx, testPrompt)
-		return err
-	case "local":
-		_, err := c.provider.(*LocalProvider).generate(ctx, testPrompt)
-		return err
-	default:
-		return fmt.Errorf("unsupported provider for connection test: %s", c.config.AI.Provider)
+	// The fallback providers standing in for a configured provider that
+	// could not be created do not count
+	if len(c.providerErrors) > 0 {
+		return errors.Unwrap(c.providerErrors[0])
 	}
+
+	req := c.NewRequest("Hello, please respond with 'OK' to confirm the connection is working.")
+	req.Kind = KindConnectionTest
+	_, err := c.providers[0].Complete(ctx, req)
+	return err
 }
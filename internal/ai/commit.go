@@ -0,0 +1,437 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// CommitMessage is a commit message in structured form
+type CommitMessage struct {
+	Type    string
+	Scope   string
+	Subject string
+	Body    string
+	// Breaking marks the commit as a breaking change
+	Breaking bool
+	// BreakingChange describes the breaking change for the footer
+	BreakingChange string
+	Footers        []string
+}
+
+// commitSchema is the JSON schema of a structured commit message
+var commitSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "type": {"type": "string", "description": "Conventional commit type, such as feat or fix"},
+    "scope": {"type": "string", "description": "Area of the code affected, or empty"},
+    "subject": {"type": "string", "description": "Imperative summary for the first line"},
+    "body": {"type": "string", "description": "What changed and why, in paragraphs, or empty"},
+    "breaking": {"type": "string", "description": "Description of the breaking change, or empty"},
+    "footers": {"type": "array", "items": {"type": "string"}, "description": "Trailers such as Refs: #123"}
+  },
+  "required": ["type", "subject"]
+}`)
+
+// commitJSONInstructions is appended to the commit prompt to ask for a
+// structured message
+const commitJSONInstructions = `
+
+Respond with only a JSON object, without markdown, using these fields:
+- "type": the conventional commit type, such as feat, fix or refactor
+- "scope": the area of the code affected, or an empty string
+- "subject": the imperative summary for the first line, without type or scope
+- "body": what changed and why, in paragraphs separated by blank lines, or an empty string for small changes
+- "breaking": a description of the breaking change, or an empty string if there is none
+- "footers": trailers such as "Refs: #123", or an empty list`
+
+// commitTextInstructions is appended to the commit prompt to ask for a
+// complete message in plain text, which can be shown while it streams and
+// parsed into structured form afterwards
+const commitTextInstructions = `
+
+Respond with only the commit message, without markdown: the header on the
+first line, then for larger changes a blank line and a body explaining what
+changed and why, then a blank line and any trailers such as "Refs: #123" or
+"BREAKING CHANGE: <description>".`
+
+// structuredMaxTokens is the smallest response size allowed for structured
+// messages, so that a body does not cut the JSON object short
+const structuredMaxTokens = 512
+
+var (
+	// commitHeaderPattern matches a conventional commit header
+	commitHeaderPattern = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+	// jsonStringFieldPattern finds string fields in JSON that failed to
+	// parse, usually because the response was cut short
+	jsonStringFieldPattern = regexp.MustCompile(`"(\w+)"\s*:\s*"((?:[^"\\]|\\.)*)"`)
+)
+
+// GenerateCommits generates up to n distinct commit messages in structured
+// form. Providers are asked for a JSON object and responses that are not
+// valid JSON are parsed as plain text.
+func (c *Client) GenerateCommits(ctx context.Context, diff string, n int) ([]CommitMessage, error) {
+	commitPrompt, replayPrompt, err := c.commitPrompt(diff)
+	if err != nil {
+		return nil, err
+	}
+
+	req := c.NewRequest(commitPrompt + commitJSONInstructions)
+	req.Kind = KindCommit
+	req.ReplayPrompt = replayPrompt + commitJSONInstructions
+	req.N = n
+	req.Schema = commitSchema
+	req.MaxTokens = c.responseTokens(structuredMaxTokens)
+
+	resp, err := c.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	choices := resp.Choices
+	if len(choices) == 0 {
+		choices = []string{resp.Content}
+	}
+
+	var messages []CommitMessage
+	for _, choice := range choices {
+		message, err := ParseCommitMessage(choice)
+		if err != nil {
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("AI generated empty commit message")
+	}
+
+	return messages, nil
+}
+
+// StreamCommit generates a commit message in structured form while passing
+// the text to onToken as it arrives. The message is requested as plain text
+// rather than JSON so that it reads naturally while streaming.
+func (c *Client) StreamCommit(ctx context.Context, diff string, onToken TokenHandler) (CommitMessage, error) {
+	commitPrompt, replayPrompt, err := c.commitPrompt(diff)
+	if err != nil {
+		return CommitMessage{}, err
+	}
+
+	req := c.NewRequest(commitPrompt + commitTextInstructions)
+	req.Kind = KindCommit
+	req.ReplayPrompt = replayPrompt + commitTextInstructions
+	req.MaxTokens = c.responseTokens(structuredMaxTokens)
+
+	resp, err := c.Stream(ctx, req, onToken)
+	if err != nil {
+		return CommitMessage{}, err
+	}
+
+	return ParseCommitMessage(resp.Content)
+}
+
+// supportsJSONOutput reports whether model can be asked for a JSON object
+// natively
+func supportsJSONOutput(cfg *config.Config, model string) bool {
+	info, ok := cfg.LookupModel(model)
+	return ok && info.JSONOutput
+}
+
+// ParseCommitMessage parses a generated commit message. It accepts a JSON
+// object, possibly wrapped in markdown or cut short, and falls back to
+// reading a plain-text message.
+func ParseCommitMessage(text string) (CommitMessage, error) {
+	text = strings.TrimSpace(text)
+
+	message, ok := parseCommitJSON(text)
+	if !ok {
+		message = parsePlainCommit(text)
+	}
+
+	// Models sometimes repeat the type and scope in the subject
+	if match := commitHeaderPattern.FindStringSubmatch(strings.TrimSpace(message.Subject)); match != nil &&
+		(message.Type == "" || strings.EqualFold(match[1], message.Type)) {
+		message.Type = match[1]
+		if match[2] != "" {
+			message.Scope = match[2]
+		}
+		message.Breaking = message.Breaking || match[3] == "!"
+		message.Subject = match[4]
+	}
+
+	message.Type = strings.ToLower(strings.TrimSpace(message.Type))
+	message.Scope = strings.TrimSpace(message.Scope)
+	message.Subject = strings.TrimSuffix(strings.TrimSpace(message.Subject), ".")
+	message.Body = strings.TrimSpace(message.Body)
+
+	// A breaking change footer goes into BreakingChange so that it is
+	// written once, after the body
+	var footers []string
+	for _, footer := range message.Footers {
+		footer = strings.TrimSpace(footer)
+		switch {
+		case footer == "":
+		case strings.HasPrefix(footer, "BREAKING CHANGE:"), strings.HasPrefix(footer, "BREAKING-CHANGE:"):
+			if message.BreakingChange == "" {
+				message.BreakingChange = footer[len("BREAKING CHANGE:"):]
+			}
+		default:
+			footers = append(footers, footer)
+		}
+	}
+	message.Footers = footers
+	message.BreakingChange = strings.TrimSpace(message.BreakingChange)
+	if message.BreakingChange != "" {
+		message.Breaking = true
+	}
+
+	if message.Subject == "" {
+		return CommitMessage{}, fmt.Errorf("AI generated empty commit message")
+	}
+
+	return message, nil
+}
+
+func parseCommitJSON(text string) (CommitMessage, bool) {
+	start := strings.Index(text, "{")
+	if start < 0 {
+		return CommitMessage{}, false
+	}
+
+	fields := map[string]json.RawMessage{}
+	end := strings.LastIndex(text, "}")
+	if end < start || json.Unmarshal([]byte(text[start:end+1]), &fields) != nil {
+		return rescueCommitJSON(text[start:])
+	}
+
+	// Models sometimes capitalize the keys
+	lower := map[string]json.RawMessage{}
+	for key, value := range fields {
+		lower[strings.ToLower(key)] = value
+	}
+
+	message := CommitMessage{
+		Type:    jsonText(lower["type"]),
+		Scope:   jsonText(lower["scope"]),
+		Subject: jsonText(lower["subject"]),
+		Body:    jsonText(lower["body"]),
+		Footers: jsonFooters(lower["footers"]),
+	}
+	if message.Subject == "" {
+		message.Subject = jsonText(lower["description"])
+	}
+
+	var breaking bool
+	if json.Unmarshal(lower["breaking"], &breaking) == nil {
+		message.Breaking = breaking
+	} else if note := jsonText(lower["breaking"]); !isNone(note) {
+		message.BreakingChange = note
+	}
+
+	return message, message.Subject != ""
+}
+
+// rescueCommitJSON reads the complete string fields of a JSON object that
+// could not be parsed
+func rescueCommitJSON(text string) (CommitMessage, bool) {
+	var message CommitMessage
+	for _, match := range jsonStringFieldPattern.FindAllStringSubmatch(text, -1) {
+		var value string
+		if json.Unmarshal([]byte(`"`+match[2]+`"`), &value) != nil {
+			continue
+		}
+		switch strings.ToLower(match[1]) {
+		case "type":
+			message.Type = value
+		case "scope":
+			message.Scope = value
+		case "subject":
+			message.Subject = value
+		case "body":
+			message.Body = value
+		case "breaking":
+			if !isNone(value) {
+				message.BreakingChange = value
+			}
+		}
+	}
+	return message, message.Subject != ""
+}
+
+// parsePlainCommit reads a plain-text message: a header, an optional body
+// and trailing footers
+func parsePlainCommit(text string) CommitMessage {
+	// Drop code fences, including any language after the opening one
+	var kept []string
+	for _, line := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "```") {
+			kept = append(kept, line)
+		}
+	}
+	text = strings.ReplaceAll(strings.Join(kept, "\n"), "`", "")
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+
+	var message CommitMessage
+	header := strings.TrimSpace(lines[0])
+	if match := commitHeaderPattern.FindStringSubmatch(header); match != nil {
+		message.Type = match[1]
+		message.Scope = match[2]
+		message.Breaking = match[3] == "!"
+		message.Subject = match[4]
+	} else {
+		message.Subject = header
+	}
+
+	// Footers form the last paragraph, which is separated from the header
+	// or body by a blank line and holds only trailers
+	rest := strings.Join(lines[1:], "\n")
+	paragraphs := strings.Split(strings.TrimSpace(rest), "\n\n")
+	last := strings.Split(paragraphs[len(paragraphs)-1], "\n")
+	footers := len(lines) > 1 && strings.TrimSpace(lines[1]) == ""
+	for _, line := range last {
+		line = strings.TrimSpace(line)
+		if !isFooter(line) && !strings.HasPrefix(line, "BREAKING CHANGE:") && !strings.HasPrefix(line, "BREAKING-CHANGE:") {
+			footers = false
+		}
+	}
+	if footers {
+		message.Footers = last
+		paragraphs = paragraphs[:len(paragraphs)-1]
+	}
+	message.Body = strings.Join(paragraphs, "\n\n")
+
+	return message
+}
+
+// String assembles the message: the header, the body and the footers, with
+// breaking changes marked in both the header and a BREAKING CHANGE footer
+func (m CommitMessage) String() string {
+	header := m.Subject
+	if m.Type != "" {
+		prefix := m.Type
+		if m.Scope != "" {
+			prefix += "(" + m.Scope + ")"
+		}
+		if m.Breaking {
+			prefix += "!"
+		}
+		header = prefix + ": " + m.Subject
+	}
+
+	parts := []string{header}
+	if m.Body != "" {
+		parts = append(parts, m.Body)
+	}
+
+	var footers []string
+	if m.BreakingChange != "" {
+		footers = append(footers, "BREAKING CHANGE: "+m.BreakingChange)
+	}
+	footers = append(footers, m.Footers...)
+	if len(footers) > 0 {
+		parts = append(parts, strings.Join(footers, "\n"))
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// jsonText decodes a string, or joins an array of strings into paragraphs
+func jsonText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+
+	var paragraphs []string
+	if json.Unmarshal(raw, &paragraphs) == nil {
+		return strings.Join(paragraphs, "\n\n")
+	}
+
+	return ""
+}
+
+// jsonFooters decodes footers given as strings, as token and value objects
+// or as a single object mapping tokens to values
+func jsonFooters(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var footers []string
+	if json.Unmarshal(raw, &footers) == nil {
+		return footers
+	}
+
+	var pairs []map[string]string
+	if json.Unmarshal(raw, &pairs) == nil {
+		for _, pair := range pairs {
+			token := pair["token"]
+			if token == "" {
+				token = pair["key"]
+			}
+			if token != "" && pair["value"] != "" {
+				footers = append(footers, token+": "+pair["value"])
+			}
+		}
+		return footers
+	}
+
+	var tokens map[string]string
+	if json.Unmarshal(raw, &tokens) == nil {
+		for token, value := range tokens {
+			footers = append(footers, token+": "+value)
+		}
+		sort.Strings(footers)
+		return footers
+	}
+
+	return nil
+}
+
+// trailerTokens are single-word trailer tokens in common use. Other tokens
+// must be hyphenated, like Signed-off-by, so that prose such as
+// "Note: see docs" is not mistaken for a trailer.
+var trailerTokens = map[string]bool{
+	"refs": true, "ref": true, "fixes": true, "closes": true, "resolves": true,
+	"cc": true, "link": true, "bug": true, "issue": true, "issues": true,
+}
+
+// isFooter reports whether line is a git trailer such as Refs: #123 or a
+// conventional commit footer such as Fixes #123
+func isFooter(line string) bool {
+	token, value, found := strings.Cut(line, ": ")
+	if !found {
+		token, value, found = strings.Cut(line, " #")
+	}
+	if !found || strings.TrimSpace(value) == "" || token == "" {
+		return false
+	}
+	for _, r := range token {
+		if !(r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	if token[0] == '-' || token[len(token)-1] == '-' {
+		return false
+	}
+	return strings.Contains(token, "-") || trailerTokens[strings.ToLower(token)]
+}
+
+// isNone reports whether a breaking change note means there is none
+func isNone(note string) bool {
+	switch strings.ToLower(strings.TrimSpace(note)) {
+	case "", "false", "none", "no", "n/a":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package ai
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommitMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want CommitMessage
+	}{
+		{
+			name: "json",
+			text: `{"type": "feat", "scope": "auth", "subject": "add login.", "body": "Adds a login form.", "breaking": "none", "footers": ["Refs: #12"]}`,
+			want: CommitMessage{Type: "feat", Scope: "auth", Subject: "add login", Body: "Adds a login form.", Footers: []string{"Refs: #12"}},
+		},
+		{
+			name: "json with capitalized keys and breaking note",
+			text: `{"Type": "Fix", "Subject": "drop v1 API", "Breaking": "v1 clients must upgrade"}`,
+			want: CommitMessage{Type: "fix", Subject: "drop v1 API", Breaking: true, BreakingChange: "v1 clients must upgrade"},
+		},
+		{
+			name: "json with header in subject",
+			text: `{"type": "feat", "subject": "feat(ui)!: redesign menu"}`,
+			want: CommitMessage{Type: "feat", Scope: "ui", Subject: "redesign menu", Breaking: true},
+		},
+		{
+			name: "truncated json",
+			text: `{"type": "fix", "scope": "cli", "subject": "handle \"quoted\" args", "body": "Quotes are now kept when`,
+			want: CommitMessage{Type: "fix", Scope: "cli", Subject: `handle "quoted" args`},
+		},
+		{
+			name: "fenced json",
+			text: "```json\n{\"type\": \"docs\", \"subject\": \"explain setup\"}\n```",
+			want: CommitMessage{Type: "docs", Subject: "explain setup"},
+		},
+		{
+			name: "fenced plain text",
+			text: "```\nchore: bump deps\n```",
+			want: CommitMessage{Type: "chore", Subject: "bump deps"},
+		},
+		{
+			name: "fenced plain text with language",
+			text: "```text\nchore: bump deps\n```",
+			want: CommitMessage{Type: "chore", Subject: "bump deps"},
+		},
+		{
+			name: "plain text with body and footers",
+			text: "feat(api): add paging\n\nList endpoints take a cursor.\nNote: the default page size is 50.\n\nCloses #42\nReviewed-by: Sam",
+			want: CommitMessage{
+				Type:    "feat",
+				Scope:   "api",
+				Subject: "add paging",
+				Body:    "List endpoints take a cursor.\nNote: the default page size is 50.",
+				Footers: []string{"Closes #42", "Reviewed-by: Sam"},
+			},
+		},
+		{
+			name: "plain text body line with colon is not a footer",
+			text: "fix: retry uploads\n\nWarning: uploads over 1GB still fail.",
+			want: CommitMessage{Type: "fix", Subject: "retry uploads", Body: "Warning: uploads over 1GB still fail."},
+		},
+		{
+			name: "plain text breaking change footer",
+			text: "refactor!: rename config keys\n\nBREAKING CHANGE: ai.key is now ai.api_key",
+			want: CommitMessage{Type: "refactor", Subject: "rename config keys", Breaking: true, BreakingChange: "ai.key is now ai.api_key"},
+		},
+		{
+			name: "plain text without type",
+			text: "Update the readme",
+			want: CommitMessage{Subject: "Update the readme"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCommitMessage(tt.text)
+			if err != nil {
+				t.Fatalf("ParseCommitMessage() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCommitMessage() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCommitJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		ok   bool
+	}{
+		{"object", `{"subject": "add x"}`, true},
+		{"description instead of subject", `{"description": "add x"}`, true},
+		{"prose around object", `Here you go: {"subject": "add x"} Hope this helps`, true},
+		{"no subject", `{"type": "feat"}`, false},
+		{"no object", "feat: add x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseCommitJSON(tt.text)
+			if ok != tt.ok {
+				t.Fatalf("parseCommitJSON() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && got.Subject != "add x" {
+				t.Errorf("Subject = %q, want %q", got.Subject, "add x")
+			}
+		})
+	}
+}
+
+func TestRescueCommitJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want CommitMessage
+		ok   bool
+	}{
+		{
+			name: "cut inside body",
+			text: `{"type": "feat", "subject": "add x", "body": "unfinished`,
+			want: CommitMessage{Type: "feat", Subject: "add x"},
+			ok:   true,
+		},
+		{
+			name: "breaking note",
+			text: `{"subject": "drop y", "breaking": "y is gone", "footers": [`,
+			want: CommitMessage{Subject: "drop y", BreakingChange: "y is gone"},
+			ok:   true,
+		},
+		{
+			name: "cut inside subject",
+			text: `{"type": "feat", "subject": "add`,
+			want: CommitMessage{Type: "feat"},
+			ok:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := rescueCommitJSON(tt.text)
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rescueCommitJSON() = %#v, %v, want %#v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParsePlainCommit(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want CommitMessage
+	}{
+		{
+			name: "header only",
+			text: "fix(db): close rows",
+			want: CommitMessage{Type: "fix", Scope: "db", Subject: "close rows"},
+		},
+		{
+			name: "footer without blank line is body",
+			text: "fix: close rows\nRefs: #3",
+			want: CommitMessage{Type: "fix", Subject: "close rows", Body: "Refs: #3"},
+		},
+		{
+			name: "mixed last paragraph is body",
+			text: "fix: close rows\n\nRefs: #3\nAlso closes the statement.",
+			want: CommitMessage{Type: "fix", Subject: "close rows", Body: "Refs: #3\nAlso closes the statement."},
+		},
+		{
+			name: "footers after header",
+			text: "feat!: drop v1\n\nBREAKING-CHANGE: v1 is gone\nCo-authored-by: Kim <kim@example.com>",
+			want: CommitMessage{
+				Type:     "feat",
+				Subject:  "drop v1",
+				Breaking: true,
+				Footers:  []string{"BREAKING-CHANGE: v1 is gone", "Co-authored-by: Kim <kim@example.com>"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parsePlainCommit(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePlainCommit() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsFooter(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"Refs: #12", true},
+		{"Fixes #12", true},
+		{"closes: JIRA-4", true},
+		{"Signed-off-by: Alex <alex@example.com>", true},
+		{"Reviewed-by: Sam", true},
+		{"Note: the default changed", false},
+		{"Warning: slow", false},
+		{"See http://example.com", false},
+		{"time: 10:30", false},
+		{"-by: x", false},
+		{"Refs:", false},
+		{"Refs: ", false},
+		{"The fix: handle nil", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := isFooter(tt.line); got != tt.want {
+				t.Errorf("isFooter(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package ai
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/anans9/ai-git/internal/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// NewOpenAICompatibleProvider creates a provider for servers that implement
+// the OpenAI chat completions API, such as vLLM, LM Studio or LiteLLM. The
+// API key is optional and extra headers are sent with every request.
+func NewOpenAICompatibleProvider(cfg *config.Config, name string) (*OpenAIProvider, error) {
+	providerConfig, err := cfg.GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if providerConfig.BaseURL == "" {
+		return nil, fmt.Errorf("base_url is required for OpenAI-compatible provider %s", name)
+	}
+
+	httpClient, err := newHTTPClient(cfg, name, 0)
+	if err != nil {
+		return nil, err
+	}
+	httpClient.Transport = &headerTransport{
+		base:    httpClient.Transport,
+		headers: compatibleHeaders(providerConfig),
+	}
+
+	clientConfig := openai.DefaultConfig("")
+	clientConfig.BaseURL = strings.TrimSuffix(providerConfig.BaseURL, "/")
+	clientConfig.HTTPClient = httpClient
+
+	// ai.model applies when this is the default provider, as for OpenAI
+	model := cfg.ModelFor(name)
+	if model == "" {
+		model = providerConfig.Model
+	}
+
+	return &OpenAIProvider{
+		name:   name,
+		label:  name,
+		model:  model,
+		client: openai.NewClientWithConfig(clientConfig),
+		config: cfg,
+	}, nil
+}
+
+// compatibleHeaders builds the headers sent to an OpenAI-compatible server,
+// including the API key under the configured auth header and scheme
+func compatibleHeaders(providerConfig config.AIProvider) http.Header {
+	headers := http.Header{}
+
+	if providerConfig.APIKey != "" {
+		authHeader := providerConfig.AuthHeader
+		if authHeader == "" {
+			authHeader = "Authorization"
+		}
+
+		value := providerConfig.APIKey
+		scheme := providerConfig.AuthScheme
+		if scheme == "" && http.CanonicalHeaderKey(authHeader) == "Authorization" {
+			scheme = "Bearer"
+		}
+		if scheme != "" {
+			value = scheme + " " + value
+		}
+		headers.Set(authHeader, value)
+	}
+
+	for key, value := range providerConfig.Headers {
+		headers.Set(key, value)
+	}
+
+	return headers
+}
+
+// headerTransport replaces the authorization set by the OpenAI client with
+// the configured headers
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Del("Authorization")
+	for key, values := range t.headers {
+		req.Header[key] = values
+	}
+	return t.base.RoundTrip(req)
+}
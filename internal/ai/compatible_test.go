@@ -0,0 +1,175 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// compatibleServer is a stub OpenAI-compatible server that records the last
+// request and answers with status, or with a completion when status is 200
+type compatibleServer struct {
+	*httptest.Server
+	status  int
+	path    string
+	headers http.Header
+	model   string
+}
+
+func newCompatibleServer(t *testing.T, status int) *compatibleServer {
+	t.Helper()
+
+	s := &compatibleServer{status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.path = r.URL.Path
+		s.headers = r.Header.Clone()
+
+		var body struct {
+			Model string `json:"model"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		s.model = body.Model
+
+		w.Header().Set("Content-Type", "application/json")
+		if s.status != http.StatusOK {
+			w.WriteHeader(s.status)
+			w.Write([]byte(`{"error":{"message":"stub failure","type":"invalid_request_error"}}`))
+			return
+		}
+		w.Write([]byte(`{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"feat: add stub"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// compatibleConfig configures the stub as provider "vllm"
+func compatibleConfig(baseURL string, provider config.AIProvider) *config.Config {
+	provider.Type = "openai-compatible"
+	provider.BaseURL = baseURL
+	provider.Enabled = true
+
+	return &config.Config{
+		AI: config.AIConfig{
+			Provider:  "openai",
+			Model:     "gpt-4",
+			Retry:     config.RetryConfig{MaxAttempts: 1},
+			Providers: map[string]config.AIProvider{"vllm": provider},
+		},
+	}
+}
+
+func completeCompatible(t *testing.T, cfg *config.Config) (Response, error) {
+	t.Helper()
+
+	provider, err := NewProvider(cfg, "vllm")
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	return provider.Complete(context.Background(), Request{Prompt: "describe the diff"})
+}
+
+func TestCompatibleProviderHeaders(t *testing.T) {
+	server := newCompatibleServer(t, http.StatusOK)
+	cfg := compatibleConfig(server.URL+"/v1/", config.AIProvider{
+		APIKey:     "secret",
+		AuthHeader: "X-Api-Key",
+		Model:      "qwen2.5-coder",
+		Headers:    map[string]string{"X-Team": "tools"},
+	})
+
+	resp, err := completeCompatible(t, cfg)
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Content != "feat: add stub" {
+		t.Errorf("Content = %q, want %q", resp.Content, "feat: add stub")
+	}
+
+	if server.path != "/v1/chat/completions" {
+		t.Errorf("path = %q, want /v1/chat/completions", server.path)
+	}
+	if got := server.headers.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, want it removed", got)
+	}
+	if got := server.headers.Get("X-Api-Key"); got != "secret" {
+		t.Errorf("X-Api-Key = %q, want %q", got, "secret")
+	}
+	if got := server.headers.Get("X-Team"); got != "tools" {
+		t.Errorf("X-Team = %q, want %q", got, "tools")
+	}
+	if server.model != "qwen2.5-coder" {
+		t.Errorf("model = %q, want the provider's model", server.model)
+	}
+}
+
+func TestCompatibleProviderBearerAuth(t *testing.T) {
+	server := newCompatibleServer(t, http.StatusOK)
+	cfg := compatibleConfig(server.URL, config.AIProvider{APIKey: "secret"})
+
+	if _, err := completeCompatible(t, cfg); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if got := server.headers.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want %q", got, "Bearer secret")
+	}
+}
+
+func TestCompatibleProviderNoAPIKey(t *testing.T) {
+	server := newCompatibleServer(t, http.StatusOK)
+	cfg := compatibleConfig(server.URL, config.AIProvider{})
+
+	if _, err := completeCompatible(t, cfg); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if got := server.headers.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, want none without an API key", got)
+	}
+}
+
+func TestCompatibleProviderModel(t *testing.T) {
+	server := newCompatibleServer(t, http.StatusOK)
+	cfg := compatibleConfig(server.URL, config.AIProvider{Model: "qwen2.5-coder"})
+	cfg.AI.Provider = "vllm"
+	cfg.AI.Model = "llama3.1"
+
+	if _, err := completeCompatible(t, cfg); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if server.model != "llama3.1" {
+		t.Errorf("model = %q, want ai.model for the default provider", server.model)
+	}
+}
+
+func TestCompatibleProviderErrors(t *testing.T) {
+	tests := []struct {
+		status   int
+		fallback bool
+	}{
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusServiceUnavailable, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := newCompatibleServer(t, tt.status)
+			cfg := compatibleConfig(server.URL, config.AIProvider{})
+
+			_, err := completeCompatible(t, cfg)
+			if err == nil {
+				t.Fatal("Complete() error = nil, want an error")
+			}
+			if got := isFallbackError(err); got != tt.fallback {
+				t.Errorf("isFallbackError(%v) = %v, want %v", err, got, tt.fallback)
+			}
+		})
+	}
+}
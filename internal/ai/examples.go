@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// HistoryCommit is a commit from the repository's history that may serve as
+// an example of its commit style
+type HistoryCommit struct {
+	Message string
+	Files   []string
+}
+
+// maxExampleLength is the longest message used in full as an example.
+// Longer messages contribute only their header.
+const maxExampleLength = 500
+
+// maxExampleHeader is the longest header of a well-formed commit
+const maxExampleHeader = 72
+
+// examplesInstructions introduces examples added to prompt templates that do
+// not place them with {{.Examples}}
+const examplesInstructions = "Examples of commit messages in this repository; match their style, scopes and casing:\n"
+
+// SelectExamples picks up to n well-formed messages from history, which is
+// ordered newest first. Commits that touched the same files or directories
+// as paths are preferred, then the most recent ones. With conventional set,
+// only conventional commit messages qualify.
+func SelectExamples(history []HistoryCommit, paths []string, n int, conventional bool) []string {
+	changed := map[string]bool{}
+	dirs := map[string]bool{}
+	for _, p := range paths {
+		changed[p] = true
+		dirs[path.Dir(p)] = true
+	}
+
+	type candidate struct {
+		message string
+		score   int
+	}
+
+	var candidates []candidate
+	for _, commit := range history {
+		message := exampleMessage(commit.Message, conventional)
+		if message == "" {
+			continue
+		}
+
+		score := 0
+		for _, file := range commit.Files {
+			switch {
+			case changed[file]:
+				score += 2
+			case dirs[path.Dir(file)]:
+				score++
+			}
+		}
+		candidates = append(candidates, candidate{message: message, score: score})
+	}
+
+	// The stable sort keeps newer commits first among equal scores
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	var examples []string
+	seen := map[string]bool{}
+	for _, candidate := range candidates {
+		if len(examples) == n {
+			break
+		}
+		header := strings.SplitN(candidate.message, "\n", 2)[0]
+		if seen[header] {
+			continue
+		}
+		seen[header] = true
+		examples = append(examples, candidate.message)
+	}
+	return examples
+}
+
+// exampleMessage returns the message as used in an example, or "" if it is
+// not well formed: merges, reverts, fixups and work in progress are skipped,
+// as are overlong headers
+func exampleMessage(message string, conventional bool) string {
+	message = strings.TrimSpace(message)
+	header := strings.TrimSpace(strings.SplitN(message, "\n", 2)[0])
+	if header == "" || utf8.RuneCountInString(header) > maxExampleHeader {
+		return ""
+	}
+
+	lower := strings.ToLower(header)
+	for _, prefix := range []string{"merge ", "revert ", "fixup!", "squash!", "amend!", "wip:", "wip "} {
+		if strings.HasPrefix(lower, prefix) {
+			return ""
+		}
+	}
+	if lower == "wip" {
+		return ""
+	}
+
+	if conventional && !commitHeaderPattern.MatchString(header) {
+		return ""
+	}
+
+	if len(message) > maxExampleLength {
+		return header
+	}
+	return message
+}
+
+// withExamples puts examples before a rendered prompt whose template does not
+// place them itself
+func withExamples(rendered, tmpl string, examples []string) string {
+	if len(examples) == 0 || strings.Contains(tmpl, ".Examples") {
+		return rendered
+	}
+
+	var result strings.Builder
+	result.WriteString(examplesInstructions)
+	for _, example := range examples {
+		result.WriteString("---\n" + example + "\n")
+	}
+	result.WriteString("---\n\n")
+	result.WriteString(rendered)
+	return result.String()
+}
@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExampleMessage(t *testing.T) {
+	long := "feat: add x\n\n" + strings.Repeat("More detail. ", 50)
+
+	tests := []struct {
+		name         string
+		message      string
+		conventional bool
+		want         string
+	}{
+		{"plain", "Add login form\n", false, "Add login form"},
+		{"conventional", "feat(auth): add login\n\nWith a form.", true, "feat(auth): add login\n\nWith a form."},
+		{"not conventional", "Add login form", true, ""},
+		{"merge", "Merge branch 'main' into dev", false, ""},
+		{"merge pull request", "Merge pull request #4 from x/y", false, ""},
+		{"revert", `Revert "feat: add login"`, false, ""},
+		{"fixup", "fixup! feat: add login", false, ""},
+		{"squash", "squash! feat: add login", false, ""},
+		{"amend", "amend! feat: add login", false, ""},
+		{"wip", "WIP", false, ""},
+		{"wip prefix", "wip: half done", false, ""},
+		{"empty", "  \n", false, ""},
+		{"overlong header", strings.Repeat("a", maxExampleHeader+1), false, ""},
+		{"long body keeps header", long, false, "feat: add x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exampleMessage(tt.message, tt.conventional); got != tt.want {
+				t.Errorf("exampleMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectExamples(t *testing.T) {
+	history := []HistoryCommit{
+		{Message: "fix: newest", Files: []string{"docs/readme.md"}},
+		{Message: "Merge branch 'dev'", Files: []string{"cmd/root.go"}},
+		{Message: "feat(cmd): same directory", Files: []string{"cmd/other.go"}},
+		{Message: "fix(cmd): same file", Files: []string{"cmd/root.go"}},
+		{Message: "fix: newest", Files: []string{"cmd/root.go"}},
+		{Message: "chore: oldest", Files: []string{"go.mod"}},
+		{Message: "Update things", Files: []string{"cmd/root.go"}},
+	}
+
+	tests := []struct {
+		name         string
+		paths        []string
+		n            int
+		conventional bool
+		want         []string
+	}{
+		{
+			name:         "scored by files",
+			paths:        []string{"cmd/root.go"},
+			n:            3,
+			conventional: true,
+			want:         []string{"fix(cmd): same file", "fix: newest", "feat(cmd): same directory"},
+		},
+		{
+			name:         "most recent without matches",
+			paths:        []string{"internal/x.go"},
+			n:            2,
+			conventional: true,
+			want:         []string{"fix: newest", "feat(cmd): same directory"},
+		},
+		{
+			name:         "duplicate headers dropped",
+			n:            10,
+			conventional: true,
+			want:         []string{"fix: newest", "feat(cmd): same directory", "fix(cmd): same file", "chore: oldest"},
+		},
+		{
+			name:  "any style",
+			paths: []string{"cmd/root.go"},
+			n:     2,
+			want:  []string{"fix(cmd): same file", "fix: newest"},
+		},
+		{
+			name:  "none wanted",
+			paths: []string{"cmd/root.go"},
+			n:     0,
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SelectExamples(history, tt.paths, tt.n, tt.conventional); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectExamples() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
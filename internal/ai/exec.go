@@ -0,0 +1,253 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// ExecProvider implements the Provider interface by running an external
+// program. The program receives an ExecRequest as JSON on standard input
+// and writes one or more ExecResponse objects as JSON to standard output.
+type ExecProvider struct {
+	name    string
+	command string
+	args    []string
+	model   string
+	timeout time.Duration
+	config  *config.Config
+}
+
+// ExecRequest is written to the standard input of an exec provider
+type ExecRequest struct {
+	Model        string  `json:"model,omitempty"`
+	Prompt       string  `json:"prompt"`
+	SystemPrompt string  `json:"system_prompt,omitempty"`
+	MaxTokens    int     `json:"max_tokens,omitempty"`
+	Temperature  float64 `json:"temperature"`
+	N            int     `json:"n,omitempty"`
+	Stream       bool    `json:"stream,omitempty"`
+	// Schema is set when a JSON object matching it is expected as content
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// ExecResponse is read from the standard output of an exec provider. A
+// streaming program writes one object per chunk with the text in Delta;
+// other programs write a single object with the text in Content.
+type ExecResponse struct {
+	Content string     `json:"content,omitempty"`
+	Choices []string   `json:"choices,omitempty"`
+	Delta   string     `json:"delta,omitempty"`
+	Usage   *ExecUsage `json:"usage,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// ExecUsage represents token usage reported by an exec provider
+type ExecUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ExecError is returned when the program of an exec provider cannot be run,
+// fails, times out or writes output that cannot be read. Like a network
+// failure it makes the client try the next provider.
+type ExecError struct {
+	Provider string
+	Err      error
+}
+
+func (e *ExecError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// NewExecProvider creates a provider that runs the command configured
+// under name
+func NewExecProvider(cfg *config.Config, name string) (*ExecProvider, error) {
+	providerConfig, err := cfg.GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if providerConfig.Command == "" {
+		return nil, fmt.Errorf("command is required for exec provider %s", name)
+	}
+
+	// ai.model applies when this is the default provider
+	model := cfg.ModelFor(name)
+	if model == "" {
+		model = providerConfig.Model
+	}
+
+	timeout, err := providerConfig.RequestTimeout(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExecProvider{
+		name:    name,
+		command: providerConfig.Command,
+		args:    providerConfig.Args,
+		model:   model,
+		timeout: timeout,
+		config:  cfg,
+	}, nil
+}
+
+func (p *ExecProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	return p.run(ctx, req, false, nil)
+}
+
+func (p *ExecProvider) Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error) {
+	return p.run(ctx, req, true, onToken)
+}
+
+func (p *ExecProvider) Name() string {
+	return p.name
+}
+
+// Model returns the model sent with requests
+func (p *ExecProvider) Model() string {
+	return p.model
+}
+
+func (p *ExecProvider) run(ctx context.Context, req Request, stream bool, onToken TokenHandler) (Response, error) {
+	resp, err := p.execute(ctx, req, stream, onToken)
+	if err != nil {
+		return Response{}, &ExecError{Provider: p.name, Err: err}
+	}
+	return resp, nil
+}
+
+func (p *ExecProvider) execute(ctx context.Context, req Request, stream bool, onToken TokenHandler) (Response, error) {
+	input, err := json.Marshal(ExecRequest{
+		Model:        p.model,
+		Prompt:       req.Prompt,
+		SystemPrompt: req.SystemPrompt,
+		MaxTokens:    req.MaxTokens,
+		Temperature:  req.Temperature,
+		N:            req.N,
+		Stream:       stream,
+		Schema:       req.Schema,
+	})
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command, p.args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	// Stop waiting for output held open by the program's children once it
+	// has been killed
+	cmd.WaitDelay = time.Second
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to create pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return Response{}, fmt.Errorf("failed to run %s: %w", p.command, err)
+	}
+
+	var resp Response
+	var streamed strings.Builder
+	var usage *ExecUsage
+	var readErr error
+
+	decoder := json.NewDecoder(stdout)
+	for {
+		var message ExecResponse
+		if err := decoder.Decode(&message); err != nil {
+			if !errors.Is(err, io.EOF) {
+				readErr = fmt.Errorf("failed to decode %s provider output: %w", p.name, err)
+			}
+			break
+		}
+
+		if message.Error != "" {
+			readErr = fmt.Errorf("%s provider error: %s", p.name, message.Error)
+			break
+		}
+
+		if message.Delta != "" {
+			streamed.WriteString(message.Delta)
+			if onToken != nil {
+				onToken(message.Delta)
+			}
+		}
+		if message.Content != "" {
+			resp.Content = message.Content
+		}
+		if len(message.Choices) > 0 {
+			resp.Choices = message.Choices
+		}
+		if message.Usage != nil {
+			usage = message.Usage
+		}
+	}
+
+	// Let the program finish writing so Wait does not block on the pipe
+	io.Copy(io.Discard, stdout)
+
+	if err := cmd.Wait(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return Response{}, fmt.Errorf("%s provider timed out: %w", p.name, ctx.Err())
+		}
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return Response{}, fmt.Errorf("%s provider command failed: %w: %s", p.name, err, detail)
+		}
+		return Response{}, fmt.Errorf("%s provider command failed: %w", p.name, err)
+	}
+	if readErr != nil {
+		return Response{}, readErr
+	}
+
+	if resp.Content == "" {
+		resp.Content = streamed.String()
+	} else if streamed.Len() == 0 && onToken != nil {
+		// The program does not stream, so hand over the whole text at once
+		onToken(resp.Content)
+	}
+
+	resp.Content = strings.TrimSpace(resp.Content)
+	resp.Choices = uniqueNonEmpty(resp.Choices)
+	if resp.Content == "" && len(resp.Choices) > 0 {
+		resp.Content = resp.Choices[0]
+	}
+	if resp.Content == "" {
+		return Response{}, fmt.Errorf("no response from %s provider", p.name)
+	}
+
+	if usage != nil {
+		resp.Usage = Usage{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+		}
+		recordUsage(ctx, p.model, resp.Usage)
+	} else {
+		recordEstimatedUsage(ctx, p.config, p.model, req.SystemPrompt+req.Prompt, resp.Content)
+	}
+
+	return resp, nil
+}
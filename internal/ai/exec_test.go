@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+func newTestExecProvider(t *testing.T, script string) *ExecProvider {
+	t.Helper()
+
+	cfg := &config.Config{AI: config.AIConfig{
+		Provider: "openai",
+		Model:    "gpt-4",
+		Providers: map[string]config.AIProvider{
+			"script": {Type: "exec", Command: "sh", Args: []string{"-c", script}, Timeout: "100ms", Enabled: true},
+		},
+	}}
+	provider, err := NewExecProvider(cfg, "script")
+	if err != nil {
+		t.Fatalf("NewExecProvider() error = %v", err)
+	}
+	return provider
+}
+
+func TestExecProviderFailuresFallBack(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"non-zero exit", "cat >/dev/null; echo broken >&2; exit 3"},
+		{"timeout", "sleep 5"},
+		{"bad json", `cat >/dev/null; echo '{"content":'`},
+		{"program error", `cat >/dev/null; echo '{"error":"no model"}'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newStubClient(
+				newTestExecProvider(t, tt.script),
+				&stubProvider{name: "backup", content: "feat: add x"},
+			)
+
+			resp, err := client.Complete(context.Background(), Request{Prompt: "x"})
+			if err != nil {
+				t.Fatalf("Complete() error = %v, want the backup provider to answer", err)
+			}
+			if resp.Content != "feat: add x" {
+				t.Errorf("Content = %q, want the backup's answer", resp.Content)
+			}
+		})
+	}
+}
+
+func TestExecProviderModel(t *testing.T) {
+	provider := newTestExecProvider(t, "true")
+	if provider.Model() != "gpt-4" {
+		t.Errorf("Model() = %q, want ai.model for a provider without its own", provider.Model())
+	}
+
+	cfg := &config.Config{AI: config.AIConfig{
+		Provider: "script",
+		Model:    "llama3",
+		Providers: map[string]config.AIProvider{
+			"script": {Type: "exec", Command: "true", Model: "mistral", Enabled: true},
+		},
+	}}
+	provider, err := NewExecProvider(cfg, "script")
+	if err != nil {
+		t.Fatalf("NewExecProvider() error = %v", err)
+	}
+	if provider.Model() != "llama3" {
+		t.Errorf("Model() = %q, want ai.model for the default provider", provider.Model())
+	}
+}
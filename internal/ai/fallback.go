@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/anans9/ai-git/internal/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// StatusError is returned when a provider answers with a non-success HTTP status
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s API error (status %d): %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// FallbackError records a provider failure that caused the client to try
+// the next provider in the fallback chain
+type FallbackError struct {
+	Provider string
+	Err      error
+}
+
+func (e *FallbackError) Error() string {
+	return fmt.Sprintf("%s failed: %v", e.Provider, e.Err)
+}
+
+func (e *FallbackError) Unwrap() error {
+	return e.Err
+}
+
+// newFallbackProviders creates the enabled providers listed in ai.fallback.
+// Providers that are disabled, repeated or cannot be created are skipped.
+func newFallbackProviders(cfg *config.Config) []Provider {
+	seen := map[string]bool{cfg.AI.Provider: true}
+
+	var providers []Provider
+	for _, name := range cfg.AI.Fallback {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		providerConfig, err := cfg.GetProvider(name)
+		if err != nil || !providerConfig.Enabled {
+			continue
+		}
+
+		provider, err := NewProvider(cfg, name)
+		if err != nil {
+			continue
+		}
+		providers = append(providers, provider)
+	}
+
+	return providers
+}
+
+// withFallback runs call against each provider in turn until one succeeds
+// or fails with an error that another provider would not fix. The provider
+// that produced the result is returned and becomes the client's current
+// provider.
+func (c *Client) withFallback(ctx context.Context, call func(ctx context.Context, provider Provider) error) (Provider, error) {
+	var fallbackErrors []error
+
+	var err error
+	for i, provider := range c.providers {
+		attemptCtx, cancel := attemptContext(ctx, len(c.providers)-i)
+		callCtx, recorder := withUsageRecorder(attemptCtx)
+		err = call(callCtx, provider)
+		cancel()
+		c.logUsage(provider, recorder)
+		if err == nil {
+			c.setProvider(provider, fallbackErrors)
+			return provider, nil
+		}
+
+		if i == len(c.providers)-1 || ctx.Err() != nil || !isFallbackError(err) {
+			break
+		}
+		fallbackErrors = append(fallbackErrors, &FallbackError{Provider: provider.Name(), Err: err})
+	}
+
+	c.setProvider(nil, fallbackErrors)
+	if len(c.providerErrors) > 0 {
+		// Say why the configured provider did not answer either
+		err = fmt.Errorf("%w (%v)", err, c.providerErrors[0])
+	}
+	return nil, err
+}
+
+// attemptContext limits the attempt of one of the remaining providers to an
+// equal share of the time left before the deadline of ctx, so that a hung
+// provider leaves time for the fallback providers after it
+func attemptContext(ctx context.Context, remaining int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || remaining <= 1 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Until(deadline)/time.Duration(remaining))
+}
+
+// isFallbackError reports whether err means the provider is unavailable, so
+// that another provider may succeed: network failures, timeouts,
+// authentication errors, rate limits, server errors and failed exec
+// provider programs
+func isFallbackError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var execErr *ExecError
+	if errors.As(err, &execErr) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	status := 0
+	var statusErr *StatusError
+	var apiErr *openai.APIError
+	var requestErr *openai.RequestError
+	switch {
+	case errors.As(err, &statusErr):
+		status = statusErr.StatusCode
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &requestErr):
+		status = requestErr.HTTPStatusCode
+	default:
+		return false
+	}
+
+	return status == http.StatusUnauthorized ||
+		status == http.StatusForbidden ||
+		isRetryableStatus(status)
+}
@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// stubProvider answers with content, or with err, after waiting for delay
+// or until its context ends
+type stubProvider struct {
+	name    string
+	delay   time.Duration
+	content string
+	err     error
+}
+
+func (p *stubProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return Response{}, ctx.Err()
+	}
+	if p.err != nil {
+		return Response{}, p.err
+	}
+	return Response{Content: p.content}, nil
+}
+
+func (p *stubProvider) Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error) {
+	return p.Complete(ctx, req)
+}
+
+func (p *stubProvider) Name() string {
+	return p.name
+}
+
+func newStubClient(providers ...Provider) *Client {
+	return &Client{
+		config:    &config.Config{},
+		provider:  providers[0],
+		providers: providers,
+	}
+}
+
+func TestFallbackAfterHungProvider(t *testing.T) {
+	client := newStubClient(
+		&stubProvider{name: "hung", delay: time.Hour},
+		&stubProvider{name: "backup", content: "feat: add x"},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	resp, err := client.Complete(ctx, Request{Prompt: "x"})
+	if err != nil {
+		t.Fatalf("Complete() error = %v, want the backup provider to answer", err)
+	}
+	if resp.Content != "feat: add x" || client.GetProviderName() != "backup" {
+		t.Errorf("got %q from %s, want the backup's answer", resp.Content, client.GetProviderName())
+	}
+	if errs := client.FallbackErrors(); len(errs) != 1 || !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Errorf("FallbackErrors() = %v, want the hung provider's timeout", errs)
+	}
+}
+
+func TestFallbackStopsOnOtherErrors(t *testing.T) {
+	client := newStubClient(
+		&stubProvider{name: "primary", err: errors.New("invalid prompt")},
+		&stubProvider{name: "backup", content: "feat: add x"},
+	)
+
+	if _, err := client.Complete(context.Background(), Request{Prompt: "x"}); err == nil {
+		t.Fatal("Complete() error = nil, want the primary's error")
+	}
+}
+
+func TestFallbackLastProviderGetsRemainingTime(t *testing.T) {
+	client := newStubClient(&stubProvider{name: "slow", delay: 100 * time.Millisecond, content: "ok"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := client.Complete(ctx, Request{Prompt: "x"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+}
+
+func TestAttemptContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	attempt, cancelAttempt := attemptContext(ctx, 2)
+	defer cancelAttempt()
+
+	deadline, _ := attempt.Deadline()
+	if left := time.Until(deadline); left > 600*time.Millisecond || left < 400*time.Millisecond {
+		t.Errorf("attempt has %s, want half of the second left", left)
+	}
+
+	unlimited, cancelUnlimited := attemptContext(context.Background(), 2)
+	defer cancelUnlimited()
+	if _, ok := unlimited.Deadline(); ok {
+		t.Error("attempt without an operation deadline has a deadline")
+	}
+}
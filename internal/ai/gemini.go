@@ -0,0 +1,373 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// defaultGeminiBaseURL is the Gemini API endpoint used when no base_url is set
+const defaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// GeminiProvider implements the Provider interface for Google Gemini
+type GeminiProvider struct {
+	name    string
+	apiKey  string
+	baseURL string
+	model   string
+	config  *config.Config
+	client  *http.Client
+}
+
+// GeminiRequest represents a generateContent request
+type GeminiRequest struct {
+	Contents          []GeminiContent        `json:"contents"`
+	SystemInstruction *GeminiContent         `json:"systemInstruction,omitempty"`
+	GenerationConfig  GeminiGenerationConfig `json:"generationConfig"`
+}
+
+// GeminiContent represents a message made of parts
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart represents a part of a message
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+// GeminiGenerationConfig holds the sampling settings
+type GeminiGenerationConfig struct {
+	Temperature      float64 `json:"temperature"`
+	MaxOutputTokens  int     `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string  `json:"responseMimeType,omitempty"`
+}
+
+// GeminiResponse represents a generateContent response
+type GeminiResponse struct {
+	Candidates     []GeminiCandidate     `json:"candidates"`
+	PromptFeedback *GeminiPromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *GeminiUsageMetadata  `json:"usageMetadata,omitempty"`
+}
+
+// GeminiCandidate represents a generated candidate
+type GeminiCandidate struct {
+	Content       GeminiContent        `json:"content"`
+	FinishReason  string               `json:"finishReason"`
+	SafetyRatings []GeminiSafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// GeminiPromptFeedback reports whether the prompt itself was blocked
+type GeminiPromptFeedback struct {
+	BlockReason   string               `json:"blockReason,omitempty"`
+	SafetyRatings []GeminiSafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// GeminiSafetyRating represents the safety rating of a category
+type GeminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// GeminiUsageMetadata represents token usage reported by Gemini
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// SafetyBlockError is returned when Gemini refuses to answer because the
+// prompt or the response was blocked
+type SafetyBlockError struct {
+	// Reason is the block reason or finish reason, such as SAFETY
+	Reason string
+	// Categories lists the safety categories that caused the block
+	Categories []string
+	// Prompt reports whether the prompt rather than the response was blocked
+	Prompt bool
+}
+
+func (e *SafetyBlockError) Error() string {
+	target := "response"
+	if e.Prompt {
+		target = "prompt"
+	}
+	if len(e.Categories) == 0 {
+		return fmt.Sprintf("Gemini blocked the %s (%s)", target, e.Reason)
+	}
+	return fmt.Sprintf("Gemini blocked the %s (%s: %s)", target, e.Reason, strings.Join(e.Categories, ", "))
+}
+
+// geminiBlockReasons lists the finish reasons that mean a response was
+// withheld rather than completed
+var geminiBlockReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+}
+
+// NewGeminiProvider creates a new Gemini provider
+func NewGeminiProvider(cfg *config.Config, name string) (*GeminiProvider, error) {
+	providerConfig, err := cfg.GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if providerConfig.APIKey == "" {
+		return nil, fmt.Errorf("Gemini API key is required")
+	}
+
+	baseURL := providerConfig.BaseURL
+	if baseURL == "" {
+		baseURL = defaultGeminiBaseURL
+	}
+
+	// ai.model applies when this is the default provider
+	model := cfg.ModelFor(name)
+	if model == "" {
+		model = providerConfig.Model
+	}
+
+	httpClient, err := newHTTPClient(cfg, name, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GeminiProvider{
+		name:    name,
+		apiKey:  providerConfig.APIKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		config:  cfg,
+		client:  httpClient,
+	}, nil
+}
+
+// Complete sends a generateContent request. Alternative completions are
+// requested concurrently since not every model supports candidateCount.
+func (p *GeminiProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	return completeParallel(ctx, req, p.complete)
+}
+
+func (p *GeminiProvider) Name() string {
+	return p.name
+}
+
+// Model returns the model sent with requests
+func (p *GeminiProvider) Model() string {
+	return p.model
+}
+
+func (p *GeminiProvider) newRequest(ctx context.Context, req Request, stream bool) (*http.Request, error) {
+	geminiReq := GeminiRequest{
+		Contents: []GeminiContent{
+			{
+				Role:  "user",
+				Parts: []GeminiPart{{Text: req.Prompt}},
+			},
+		},
+		GenerationConfig: GeminiGenerationConfig{
+			Temperature:     req.Temperature,
+			MaxOutputTokens: req.MaxTokens,
+		},
+	}
+	if len(req.Schema) > 0 && supportsJSONOutput(p.config, p.model) {
+		geminiReq.GenerationConfig.ResponseMimeType = "application/json"
+	}
+	if req.SystemPrompt != "" {
+		geminiReq.SystemInstruction = &GeminiContent{
+			Parts: []GeminiPart{{Text: req.SystemPrompt}},
+		}
+	}
+
+	jsonData, err := json.Marshal(geminiReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/models/%s:generateContent", p.baseURL, p.model)
+	if stream {
+		url = fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", p.baseURL, p.model)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", p.apiKey)
+
+	return httpReq, nil
+}
+
+func (p *GeminiProvider) complete(ctx context.Context, req Request) (Response, error) {
+	httpReq, err := p.newRequest(ctx, req, false)
+	if err != nil {
+		return Response{}, err
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("Gemini API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Response{}, &StatusError{Provider: "Gemini", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var geminiResp GeminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		return Response{}, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	usage := geminiResp.usage()
+	recordUsage(ctx, p.model, usage)
+
+	if err := geminiResp.blocked(); err != nil {
+		return Response{}, err
+	}
+
+	content := strings.TrimSpace(geminiResp.text())
+	if content == "" {
+		return Response{}, fmt.Errorf("no content in Gemini response")
+	}
+
+	return Response{
+		Content: content,
+		Usage:   usage,
+	}, nil
+}
+
+// Stream sends a streamGenerateContent request and reads the server-sent events
+func (p *GeminiProvider) Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error) {
+	httpReq, err := p.newRequest(ctx, req, true)
+	if err != nil {
+		return Response{}, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("Gemini API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return Response{}, &StatusError{Provider: "Gemini", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var content strings.Builder
+	var usage Usage
+	err = readSSE(resp.Body, func(data string) error {
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
+
+		// Usage metadata is cumulative, so the last chunk holds the totals
+		if chunk.UsageMetadata != nil {
+			usage = chunk.usage()
+		}
+
+		if err := chunk.blocked(); err != nil {
+			return err
+		}
+
+		if text := chunk.text(); text != "" {
+			content.WriteString(text)
+			if onToken != nil {
+				onToken(text)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return Response{}, err
+	}
+
+	recordUsage(ctx, p.model, usage)
+
+	if content.Len() == 0 {
+		return Response{}, fmt.Errorf("no content in Gemini response")
+	}
+
+	return Response{
+		Content: strings.TrimSpace(content.String()),
+		Usage:   usage,
+	}, nil
+}
+
+// text returns the text of the first candidate
+func (r GeminiResponse) text() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+
+	var text strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+// blocked returns a SafetyBlockError if the prompt or the first candidate
+// was blocked
+func (r GeminiResponse) blocked() error {
+	if r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+		return &SafetyBlockError{
+			Reason:     r.PromptFeedback.BlockReason,
+			Categories: blockedCategories(r.PromptFeedback.SafetyRatings),
+			Prompt:     true,
+		}
+	}
+
+	if len(r.Candidates) > 0 && geminiBlockReasons[r.Candidates[0].FinishReason] {
+		return &SafetyBlockError{
+			Reason:     r.Candidates[0].FinishReason,
+			Categories: blockedCategories(r.Candidates[0].SafetyRatings),
+		}
+	}
+
+	return nil
+}
+
+func (r GeminiResponse) usage() Usage {
+	if r.UsageMetadata == nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     r.UsageMetadata.PromptTokenCount,
+		CompletionTokens: r.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      r.UsageMetadata.TotalTokenCount,
+	}
+}
+
+func blockedCategories(ratings []GeminiSafetyRating) []string {
+	var categories []string
+	for _, rating := range ratings {
+		if rating.Blocked {
+			categories = append(categories, rating.Category)
+		}
+	}
+	return categories
+}
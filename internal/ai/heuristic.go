@@ -0,0 +1,574 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/anans9/ai-git/internal/config"
+	"github.com/anans9/ai-git/internal/git"
+)
+
+// maxHeuristicSymbols is the most definitions named in a subject
+const maxHeuristicSymbols = 3
+
+// File kinds that decide the commit type
+const (
+	kindCode  = "code"
+	kindTest  = "test"
+	kindDocs  = "docs"
+	kindDeps  = "deps"
+	kindCI    = "ci"
+	kindBuild = "build"
+)
+
+var (
+	// definitionPatterns find the name of a function, method or type defined
+	// on a line of code in common languages
+	definitionPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^type\s+([A-Za-z_]\w*)\s`),
+		regexp.MustCompile(`^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`),
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\*?\s+([A-Za-z_$][\w$]*)`),
+		regexp.MustCompile(`^\s*export\s+(?:const|let|interface|type|enum)\s+([A-Za-z_$][\w$]*)`),
+		regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?(?:fn|struct|enum|trait)\s+([A-Za-z_]\w*)`),
+	}
+	// goModulePattern matches a required module and version in go.mod
+	goModulePattern = regexp.MustCompile(`^\s*(?:require\s+)?([\w.-]+\.[\w.-]+/\S+)\s+(v\S+)`)
+	// summaryFilePattern matches the file headers of a formatted diff
+	summaryFilePattern = regexp.MustCompile(`(?m)^File: (.+) \(Status: (\w)\)(?:\nChanges: (\+\d+ -\d+))?`)
+	// summaryLinePattern matches the lines of earlier heuristic summaries,
+	// which are summarized again when they do not fit
+	summaryLinePattern = regexp.MustCompile(`(?m)^- .+ \(\w(?:, \+\d+ -\d+)?\)$`)
+)
+
+// dependencyFiles are manifests and lock files that list dependencies
+var dependencyFiles = map[string]bool{
+	"go.mod": true, "go.sum": true, "package.json": true, "package-lock.json": true,
+	"yarn.lock": true, "pnpm-lock.yaml": true, "Cargo.toml": true, "Cargo.lock": true,
+	"requirements.txt": true, "poetry.lock": true, "Pipfile": true, "Pipfile.lock": true,
+	"Gemfile": true, "Gemfile.lock": true, "composer.json": true, "composer.lock": true,
+}
+
+// genericDirs are directory names too common to serve as a scope
+var genericDirs = map[string]bool{
+	".": true, "src": true, "lib": true, "pkg": true, "internal": true,
+	"app": true, "source": true, "code": true,
+}
+
+// HeuristicProvider implements the Provider interface without a model. It
+// writes commit messages from the diff alone: the type is inferred from the
+// paths, the scope from the configured scopes or directory names, and the
+// subject from the changed definitions and files. It cannot review code.
+type HeuristicProvider struct {
+	name   string
+	config *config.Config
+}
+
+// lastResortProvider returns the heuristic provider to answer when no other
+// provider can be created, if its entry is enabled. It is disabled by
+// default so that a missing API key is not hidden behind heuristic messages.
+func lastResortProvider(cfg *config.Config) (Provider, bool) {
+	if providerConfig, exists := cfg.AI.Providers["heuristic"]; !exists || !providerConfig.Enabled {
+		return nil, false
+	}
+	return &HeuristicProvider{name: "heuristic", config: cfg}, true
+}
+
+// NewHeuristicProvider creates the heuristic provider configured under name
+func NewHeuristicProvider(cfg *config.Config, name string) (*HeuristicProvider, error) {
+	if _, err := cfg.GetProvider(name); err != nil {
+		return nil, err
+	}
+
+	return &HeuristicProvider{name: name, config: cfg}, nil
+}
+
+// Complete describes the diff of req. Summaries of parts of a diff list
+// the files of each part, and connection tests always succeed.
+func (p *HeuristicProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	switch req.Kind {
+	case KindConnectionTest:
+		return Response{Content: "OK"}, nil
+	case KindSummary:
+		return Response{Content: summarizeFiles(req.Prompt)}, nil
+	case KindReview:
+		return Response{}, fmt.Errorf("the heuristic provider cannot review code")
+	case KindPRTitle, KindPRDescription:
+		return Response{}, fmt.Errorf("the heuristic provider cannot write pull request text")
+	case KindCommit:
+	default:
+		return Response{}, fmt.Errorf("the heuristic provider can only write commit messages")
+	}
+	if req.Diff == nil || len(req.Diff.Files) == 0 {
+		return Response{}, fmt.Errorf("the heuristic provider needs a diff to describe")
+	}
+
+	messages := p.commitMessages(req.Diff)
+	if n := max(req.N, 1); len(messages) > n {
+		messages = messages[:n]
+	}
+
+	choices := make([]string, 0, len(messages))
+	for _, message := range messages {
+		if len(req.Schema) > 0 {
+			choices = append(choices, commitJSON(message))
+		} else {
+			choices = append(choices, message.String())
+		}
+	}
+
+	resp := Response{Content: choices[0]}
+	if req.N > 1 {
+		resp.Choices = choices
+	}
+	return resp, nil
+}
+
+// Stream describes the diff of req, passed to onToken in one piece
+func (p *HeuristicProvider) Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error) {
+	resp, err := p.Complete(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if onToken != nil {
+		onToken(resp.Content)
+	}
+	return resp, nil
+}
+
+func (p *HeuristicProvider) Name() string {
+	return p.name
+}
+
+// commitMessages returns the message for diff, followed by alternatives
+// without the scope and with a subject naming the files
+func (p *HeuristicProvider) commitMessages(diff *git.Diff) []CommitMessage {
+	patterns := p.config.Templates.Patterns
+	files := primaryFiles(diff.Files)
+
+	commitType, scope := inferType(files)
+	commitType = allowedType(commitType, patterns.Types)
+	if scope == "" {
+		scope = inferScope(files, patterns.Scopes)
+	}
+	if scope == commitType {
+		scope = ""
+	}
+
+	subjects := []string{}
+	if commitType == "chore" && scope == kindDeps {
+		subjects = append(subjects, dependencySubject(diff.Files))
+	}
+	subjects = append(subjects, symbolSubject(files), fileSubject(files))
+
+	var messages []CommitMessage
+	seen := map[string]bool{}
+	add := func(message CommitMessage) {
+		if !patterns.Conventional {
+			message.Type, message.Scope = "", ""
+			message.Subject = capitalize(message.Subject)
+		}
+		if message.Subject == "" || utf8.RuneCountInString(message.String()) > maxExampleHeader || seen[message.String()] {
+			return
+		}
+		seen[message.String()] = true
+		messages = append(messages, message)
+	}
+
+	for _, subject := range subjects {
+		add(CommitMessage{Type: commitType, Scope: scope, Subject: subject})
+		add(CommitMessage{Type: commitType, Subject: subject})
+	}
+	if len(messages) == 0 {
+		add(CommitMessage{Type: commitType, Subject: fmt.Sprintf("update %d files", len(diff.Files))})
+	}
+
+	// Messages with the scope come first
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Scope != "" && messages[j].Scope == ""
+	})
+	return messages
+}
+
+// commitJSON formats a message as the JSON object asked for by commitSchema
+func commitJSON(message CommitMessage) string {
+	data, _ := json.Marshal(map[string]string{
+		"type":    message.Type,
+		"scope":   message.Scope,
+		"subject": message.Subject,
+		"body":    message.Body,
+	})
+	return string(data)
+}
+
+// fileKind classifies a file by its path
+func fileKind(filePath string) string {
+	base := path.Base(filePath)
+	lowerPath := strings.ToLower(filePath)
+	ext := strings.ToLower(path.Ext(base))
+
+	switch {
+	case dependencyFiles[base]:
+		return kindDeps
+	case strings.HasPrefix(lowerPath, ".github/workflows/"), strings.HasPrefix(lowerPath, ".circleci/"),
+		base == ".gitlab-ci.yml", base == "Jenkinsfile", base == ".travis.yml":
+		return kindCI
+	case base == "Makefile", base == "Dockerfile", strings.HasPrefix(base, "Dockerfile."),
+		strings.HasPrefix(base, ".goreleaser"), base == "docker-compose.yml":
+		return kindBuild
+	case strings.HasSuffix(base, "_test.go"), strings.Contains(base, ".test."), strings.Contains(base, ".spec."),
+		strings.HasPrefix(base, "test_") && ext == ".py", hasDir(lowerPath, "test", "tests", "__tests__", "testdata"):
+		return kindTest
+	case ext == ".md", ext == ".rst", ext == ".adoc", ext == ".txt", base == "LICENSE",
+		hasDir(lowerPath, "docs", "doc"):
+		return kindDocs
+	}
+	return kindCode
+}
+
+// hasDir reports whether any directory of filePath has one of names
+func hasDir(filePath string, names ...string) bool {
+	dirs := strings.Split(path.Dir(filePath), "/")
+	for _, dir := range dirs {
+		for _, name := range names {
+			if dir == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// primaryFiles returns the files that decide the message: the code when
+// there is any, since tests and docs usually accompany it, or else all files
+func primaryFiles(files []git.FileDiff) []git.FileDiff {
+	var code []git.FileDiff
+	for _, file := range files {
+		if fileKind(file.Path) == kindCode {
+			code = append(code, file)
+		}
+	}
+	if len(code) == 0 {
+		return files
+	}
+	return code
+}
+
+// inferType returns the commit type for the primary files, and the scope
+// when the type implies one
+func inferType(files []git.FileDiff) (string, string) {
+	kinds := map[string]bool{}
+	for _, file := range files {
+		kinds[fileKind(file.Path)] = true
+	}
+
+	if !kinds[kindCode] {
+		if len(kinds) > 1 {
+			return "chore", ""
+		}
+		switch {
+		case kinds[kindDeps]:
+			return "chore", kindDeps
+		case kinds[kindTest]:
+			return "test", ""
+		case kinds[kindDocs]:
+			return "docs", ""
+		case kinds[kindCI]:
+			return "ci", ""
+		case kinds[kindBuild]:
+			return "build", ""
+		}
+	}
+
+	added, removed, _ := changedSymbols(files)
+	allAdded, allRenamed, changed := true, true, 0
+	for _, file := range files {
+		allAdded = allAdded && file.Status == "A"
+		allRenamed = allRenamed && file.Status == "R"
+		changed += file.Additions + file.Deletions
+	}
+
+	switch {
+	case allAdded || len(added) > 0:
+		return "feat", ""
+	case allRenamed || len(removed) > 0 || changed > 20:
+		return "refactor", ""
+	}
+	return "fix", ""
+}
+
+// allowedType maps a commit type to one of the configured types. Types that
+// are not configured become chore.
+func allowedType(commitType string, types []string) string {
+	if len(types) == 0 {
+		return commitType
+	}
+	for _, t := range types {
+		if t == commitType {
+			return commitType
+		}
+	}
+	for _, t := range types {
+		if t == "chore" {
+			return t
+		}
+	}
+	return types[0]
+}
+
+// inferScope picks the configured scope named most often in the paths of
+// files, or else the deepest directory they share
+func inferScope(files []git.FileDiff, scopes []string) string {
+	counts := map[string]int{}
+	for _, file := range files {
+		parts := strings.Split(strings.ToLower(file.Path), "/")
+		base := parts[len(parts)-1]
+		parts[len(parts)-1] = strings.TrimSuffix(base, path.Ext(base))
+		for _, part := range parts {
+			for _, scope := range scopes {
+				if strings.ToLower(scope) == part {
+					counts[scope]++
+				}
+			}
+		}
+	}
+
+	best := ""
+	for _, scope := range scopes {
+		if counts[scope] > counts[best] {
+			best = scope
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	dir := ""
+	for i, file := range files {
+		if i == 0 {
+			dir = path.Dir(file.Path)
+			continue
+		}
+		for dir != "." && !strings.HasPrefix(file.Path, dir+"/") {
+			dir = path.Dir(dir)
+		}
+	}
+	if name := path.Base(dir); !genericDirs[name] {
+		return name
+	}
+	return ""
+}
+
+// changedSymbols returns the definitions added, removed and changed in
+// files. A definition is changed when a line in its body changed.
+func changedSymbols(files []git.FileDiff) (added, removed, changed []string) {
+	addedSet, removedSet := map[string]bool{}, map[string]bool{}
+	var addedOrder, removedOrder, changedOrder []string
+	changedSet := map[string]bool{}
+
+	for _, file := range files {
+		current := ""
+		for _, line := range strings.Split(file.Content, "\n") {
+			if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+				continue
+			}
+			if strings.HasPrefix(line, "@@") {
+				current = ""
+				continue
+			}
+			if line == "" {
+				continue
+			}
+
+			op, code := line[0], line[1:]
+			name := definitionName(code)
+			switch op {
+			case '+':
+				if name != "" && !addedSet[name] {
+					addedSet[name] = true
+					addedOrder = append(addedOrder, name)
+				}
+			case '-':
+				if name != "" && !removedSet[name] {
+					removedSet[name] = true
+					removedOrder = append(removedOrder, name)
+				}
+			case ' ':
+				if name != "" {
+					current = name
+				}
+				continue
+			default:
+				continue
+			}
+
+			if name != "" {
+				current = name
+			} else if current != "" && !changedSet[current] {
+				changedSet[current] = true
+				changedOrder = append(changedOrder, current)
+			}
+		}
+	}
+
+	// A definition both added and removed had its signature changed
+	for _, name := range addedOrder {
+		if removedSet[name] {
+			if !changedSet[name] {
+				changedSet[name] = true
+				changedOrder = append(changedOrder, name)
+			}
+		} else {
+			added = append(added, name)
+		}
+	}
+	for _, name := range removedOrder {
+		if !addedSet[name] {
+			removed = append(removed, name)
+		}
+	}
+	for _, name := range changedOrder {
+		if !addedSet[name] || removedSet[name] {
+			changed = append(changed, name)
+		}
+	}
+	return added, removed, changed
+}
+
+// definitionName returns the name defined on a line of code, or ""
+func definitionName(line string) string {
+	for _, pattern := range definitionPatterns {
+		if match := pattern.FindStringSubmatch(line); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// symbolSubject names the definitions added, removed or changed in files,
+// or returns "" when there are none or too many
+func symbolSubject(files []git.FileDiff) string {
+	added, removed, changed := changedSymbols(files)
+	switch {
+	case len(added) > 0:
+		return verbList("add", added)
+	case len(removed) > 0:
+		return verbList("remove", removed)
+	case len(changed) > 0:
+		return verbList("update", changed)
+	}
+	return ""
+}
+
+// fileSubject names the files changed
+func fileSubject(files []git.FileDiff) string {
+	verb := ""
+	for _, file := range files {
+		fileVerb := "update"
+		switch file.Status {
+		case "A":
+			fileVerb = "add"
+		case "D":
+			fileVerb = "remove"
+		case "R":
+			fileVerb = "rename"
+		}
+		if verb != "" && verb != fileVerb {
+			verb = "update"
+			break
+		}
+		verb = fileVerb
+	}
+
+	if len(files) == 1 && files[0].Status == "R" {
+		return fmt.Sprintf("rename %s to %s", path.Base(files[0].OldPath), path.Base(files[0].Path))
+	}
+	if verb == "rename" {
+		verb = "move"
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, path.Base(file.Path))
+	}
+	if len(names) > 2 {
+		return fmt.Sprintf("%s %d files", verb, len(names))
+	}
+	return verbList(verb, names)
+}
+
+// dependencySubject describes the modules updated in go.mod, or returns a
+// general subject
+func dependencySubject(files []git.FileDiff) string {
+	var modules []string
+	version := ""
+	for _, file := range files {
+		if path.Base(file.Path) != "go.mod" {
+			continue
+		}
+		for _, line := range strings.Split(file.Content, "\n") {
+			if !strings.HasPrefix(line, "+") || strings.HasPrefix(line, "+++") {
+				continue
+			}
+			if match := goModulePattern.FindStringSubmatch(line[1:]); match != nil {
+				modules = append(modules, match[1])
+				version = match[2]
+			}
+		}
+	}
+
+	switch len(modules) {
+	case 0:
+		return "update dependencies"
+	case 1:
+		return fmt.Sprintf("bump %s to %s", modules[0], version)
+	}
+	return fmt.Sprintf("update %d dependencies", len(modules))
+}
+
+// verbList joins names into a subject such as "add a, b and c". Longer lists
+// are shortened to their count.
+func verbList(verb string, names []string) string {
+	switch {
+	case len(names) == 1:
+		return verb + " " + names[0]
+	case len(names) <= maxHeuristicSymbols:
+		return verb + " " + strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+	}
+	return ""
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// summarizeFiles lists the files of a formatted part of a diff, in place of
+// a summary written by a model
+func summarizeFiles(text string) string {
+	var result strings.Builder
+	for _, match := range summaryFilePattern.FindAllStringSubmatch(text, -1) {
+		result.WriteString("- " + match[1] + " (" + match[2])
+		if match[3] != "" {
+			result.WriteString(", " + match[3])
+		}
+		result.WriteString(")\n")
+	}
+	for _, line := range summaryLinePattern.FindAllString(text, -1) {
+		result.WriteString(line + "\n")
+	}
+	return strings.TrimSuffix(result.String(), "\n")
+}
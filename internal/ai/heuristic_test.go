@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/anans9/ai-git/internal/config"
+	"github.com/anans9/ai-git/internal/git"
+)
+
+func TestHeuristicRequestKinds(t *testing.T) {
+	diff := &git.Diff{Files: []git.FileDiff{{
+		Path:      "README.md",
+		Status:    "M",
+		Additions: 1,
+		Content:   "diff --git a/README.md b/README.md\n+More docs",
+	}}}
+
+	tests := []struct {
+		name    string
+		req     Request
+		want    string
+		wantErr string
+	}{
+		{"commit", Request{Kind: KindCommit, Diff: diff}, "README.md", ""},
+		{"commit without diff", Request{Kind: KindCommit}, "", "needs a diff"},
+		{"connection test", Request{Kind: KindConnectionTest}, "OK", ""},
+		{"summary", Request{Kind: KindSummary, Prompt: "File: cmd/root.go (Status: M)\nChanges: +1 -2\n"}, "- cmd/root.go (M, +1 -2)", ""},
+		{"review", Request{Kind: KindReview, Diff: diff}, "", "cannot review code"},
+		{"pr title", Request{Kind: KindPRTitle, Diff: diff}, "", "cannot write pull request text"},
+		{"unknown", Request{Diff: diff}, "", "can only write commit messages"},
+	}
+
+	provider := &HeuristicProvider{name: "heuristic", config: &config.Config{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := provider.Complete(context.Background(), tt.req)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Complete() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if !strings.Contains(resp.Content, tt.want) {
+				t.Errorf("Content = %q, want it to contain %q", resp.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastResortProviderOptIn(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers map[string]config.AIProvider
+		want      bool
+	}{
+		{"not configured", nil, false},
+		{"disabled", map[string]config.AIProvider{"heuristic": {Enabled: false}}, false},
+		{"enabled", map[string]config.AIProvider{"heuristic": {Enabled: true}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{AI: config.AIConfig{Providers: tt.providers}}
+			if _, ok := lastResortProvider(cfg); ok != tt.want {
+				t.Errorf("lastResortProvider() ok = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
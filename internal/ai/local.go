@@ -0,0 +1,390 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// LocalProvider implements the Provider interface for local models (e.g., Ollama)
+type LocalProvider struct {
+	name    string
+	baseURL string
+	model   string
+	config  *config.Config
+	client  *http.Client
+}
+
+// LocalRequest represents a chat request to a local AI model
+type LocalRequest struct {
+	Model    string         `json:"model"`
+	Messages []LocalMessage `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Format   string         `json:"format,omitempty"`
+	Options  LocalOptions   `json:"options,omitempty"`
+}
+
+// LocalMessage represents a chat message
+type LocalMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// LocalOptions represents options for local AI models
+type LocalOptions struct {
+	Temperature float64 `json:"temperature,omitempty"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+// LocalResponse represents a chat response from a local AI model. When
+// streaming, each chunk carries part of the message.
+type LocalResponse struct {
+	Message         LocalMessage `json:"message"`
+	Done            bool         `json:"done"`
+	Error           string       `json:"error,omitempty"`
+	PromptEvalCount int          `json:"prompt_eval_count,omitempty"`
+	EvalCount       int          `json:"eval_count,omitempty"`
+}
+
+func (r LocalResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// LocalModel describes a model installed on the local server
+type LocalModel struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Details    struct {
+		Family            string `json:"family"`
+		ParameterSize     string `json:"parameter_size"`
+		QuantizationLevel string `json:"quantization_level"`
+	} `json:"details"`
+}
+
+// PullProgress reports the progress of a model download
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// NewLocalProvider creates a new local provider
+func NewLocalProvider(cfg *config.Config) (*LocalProvider, error) {
+	return newLocalProvider(cfg, "local")
+}
+
+func newLocalProvider(cfg *config.Config, name string) (*LocalProvider, error) {
+	providerConfig, err := cfg.GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := providerConfig.BaseURL
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+
+	httpClient, err := newHTTPClient(cfg, name, 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalProvider{
+		name:    name,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   providerConfig.Model,
+		config:  cfg,
+		client:  httpClient,
+	}, nil
+}
+
+// Complete sends a chat request. Alternative completions are requested
+// concurrently since the API returns a single completion per call.
+func (p *LocalProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	return completeParallel(ctx, req, p.complete)
+}
+
+func (p *LocalProvider) Name() string {
+	return p.name
+}
+
+// Model returns the model sent with requests
+func (p *LocalProvider) Model() string {
+	return p.model
+}
+
+func (p *LocalProvider) newRequest(ctx context.Context, req Request, stream bool) (*http.Request, error) {
+	messages := []LocalMessage{}
+	if req.SystemPrompt != "" {
+		messages = append(messages, LocalMessage{Role: "system", Content: req.SystemPrompt})
+	}
+	messages = append(messages, LocalMessage{Role: "user", Content: req.Prompt})
+
+	localReq := LocalRequest{
+		Model:    p.model,
+		Messages: messages,
+		Stream:   stream,
+		Options: LocalOptions{
+			Temperature: req.Temperature,
+			NumPredict:  req.MaxTokens,
+		},
+	}
+
+	if len(req.Schema) > 0 {
+		// Ollama constrains any model to valid JSON in this mode
+		localReq.Format = "json"
+	}
+
+	return p.newJSONRequest(ctx, "POST", "/api/chat", localReq)
+}
+
+func (p *LocalProvider) newJSONRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewBuffer(jsonData)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	return httpReq, nil
+}
+
+func (p *LocalProvider) complete(ctx context.Context, req Request) (Response, error) {
+	httpReq, err := p.newRequest(ctx, req, false)
+	if err != nil {
+		return Response{}, err
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("local AI API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Response{}, &StatusError{Provider: "local AI", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var localResp LocalResponse
+	if err := json.Unmarshal(body, &localResp); err != nil {
+		return Response{}, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if localResp.Error != "" {
+		return Response{}, fmt.Errorf("local AI error: %s", localResp.Error)
+	}
+
+	usage := localResp.usage()
+	recordUsage(ctx, p.model, usage)
+
+	content := strings.TrimSpace(localResp.Message.Content)
+	if content == "" {
+		return Response{}, fmt.Errorf("no content in local AI response")
+	}
+
+	return Response{
+		Content: content,
+		Usage:   usage,
+	}, nil
+}
+
+// Stream sends a chat request and reads the newline-delimited responses
+func (p *LocalProvider) Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error) {
+	httpReq, err := p.newRequest(ctx, req, true)
+	if err != nil {
+		return Response{}, err
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("local AI API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return Response{}, &StatusError{Provider: "local AI", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var content strings.Builder
+	var usage Usage
+	err = readNDJSON(resp.Body, func(line []byte) error {
+		var localResp LocalResponse
+		if err := json.Unmarshal(line, &localResp); err != nil {
+			return fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+
+		if localResp.Error != "" {
+			return fmt.Errorf("local AI stream error: %s", localResp.Error)
+		}
+
+		if token := localResp.Message.Content; token != "" {
+			content.WriteString(token)
+			if onToken != nil {
+				onToken(token)
+			}
+		}
+
+		if localResp.Done {
+			usage = localResp.usage()
+			return errStreamDone
+		}
+		return nil
+	})
+	if err != nil {
+		return Response{}, err
+	}
+
+	recordUsage(ctx, p.model, usage)
+
+	if strings.TrimSpace(content.String()) == "" {
+		return Response{}, fmt.Errorf("no content in local AI response")
+	}
+
+	return Response{
+		Content: strings.TrimSpace(content.String()),
+		Usage:   usage,
+	}, nil
+}
+
+// ListModels returns the models installed on the local server
+func (p *LocalProvider) ListModels(ctx context.Context) ([]LocalModel, error) {
+	httpReq, err := p.newJSONRequest(ctx, "GET", "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("local AI API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Provider: "local AI", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var tags struct {
+		Models []LocalModel `json:"models"`
+	}
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return tags.Models, nil
+}
+
+// HasModel reports whether the configured model is installed. A model
+// without a tag matches its latest tag.
+func (p *LocalProvider) HasModel(ctx context.Context) (bool, error) {
+	models, err := p.ListModels(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	want := p.model
+	if !strings.Contains(want, ":") {
+		want += ":latest"
+	}
+
+	for _, model := range models {
+		if model.Name == p.model || model.Name == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PullModel downloads the configured model, passing progress updates to
+// onProgress as they arrive
+func (p *LocalProvider) PullModel(ctx context.Context, onProgress func(PullProgress)) error {
+	body := map[string]interface{}{
+		"model":  p.model,
+		"name":   p.model,
+		"stream": true,
+	}
+
+	httpReq, err := p.newJSONRequest(ctx, "POST", "/api/pull", body)
+	if err != nil {
+		return err
+	}
+
+	// Downloads can take far longer than the request timeout, so rely on
+	// ctx for cancellation instead
+	resp, err := untimedClient(p.client).Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("local AI API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &StatusError{Provider: "local AI", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	succeeded := false
+	err = readNDJSON(resp.Body, func(line []byte) error {
+		var progress PullProgress
+		if err := json.Unmarshal(line, &progress); err != nil {
+			return fmt.Errorf("failed to unmarshal pull progress: %w", err)
+		}
+
+		if progress.Error != "" {
+			return fmt.Errorf("failed to pull %s: %s", p.model, progress.Error)
+		}
+
+		if onProgress != nil {
+			onProgress(progress)
+		}
+
+		if progress.Status == "success" {
+			succeeded = true
+			return errStreamDone
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !succeeded {
+		return fmt.Errorf("pull of %s ended without success", p.model)
+	}
+
+	return nil
+}
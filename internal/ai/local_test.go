@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+func TestPullModelOutlivesRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 1; i <= 4; i++ {
+			fmt.Fprintf(w, "{\"status\":\"downloading\",\"total\":4,\"completed\":%d}\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+		fmt.Fprintln(w, `{"status":"success"}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{AI: config.AIConfig{
+		Provider: "local",
+		Providers: map[string]config.AIProvider{
+			"local": {Type: "local", BaseURL: server.URL, Model: "llama3", Timeout: "50ms", Enabled: true},
+		},
+	}}
+	provider, err := NewLocalProvider(cfg)
+	if err != nil {
+		t.Fatalf("NewLocalProvider() error = %v", err)
+	}
+
+	updates := 0
+	err = provider.PullModel(context.Background(), func(PullProgress) { updates++ })
+	if err != nil {
+		t.Fatalf("PullModel() error = %v, want the pull to outlive the 50ms request timeout", err)
+	}
+	if updates != 5 {
+		t.Errorf("got %d progress updates, want 5", updates)
+	}
+}
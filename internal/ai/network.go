@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/anans9/ai-git/internal/config"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// transports holds the transport built for each network configuration, so
+// that providers share connections
+var transports sync.Map
+
+// sharedTransport returns the transport for the ai.network configuration,
+// creating it on first use
+func sharedTransport(cfg config.NetworkConfig) (*http.Transport, error) {
+	if transport, ok := transports.Load(cfg); ok {
+		return transport.(*http.Transport), nil
+	}
+
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, _ := transports.LoadOrStore(cfg, transport)
+	return actual.(*http.Transport), nil
+}
+
+// newTransport creates a transport with the configured proxy, certificate
+// authorities and client certificate. Unset options keep the defaults of
+// http.DefaultTransport, including the proxy environment variables.
+func newTransport(cfg config.NetworkConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" || cfg.NoProxy != "" {
+		proxyConfig := httpproxy.FromEnvironment()
+		if cfg.Proxy != "" {
+			proxyConfig.HTTPProxy = cfg.Proxy
+			proxyConfig.HTTPSProxy = cfg.Proxy
+		}
+		if cfg.NoProxy != "" {
+			proxyConfig.NoProxy = cfg.NoProxy
+		}
+		proxyFunc := proxyConfig.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	if cfg.CACert == "" && cfg.ClientCert == "" && !cfg.InsecureSkipVerify {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		bundle, err := os.ReadFile(expandHome(cfg.CACert))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(expandHome(cfg.ClientCert), expandHome(cfg.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// expandHome replaces a leading ~ in path with the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// Factory creates a provider from the configuration stored under name in
+// ai.providers
+type Factory func(cfg *config.Config, name string) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+func init() {
+	Register("openai", func(cfg *config.Config, name string) (Provider, error) {
+		provider, err := newOpenAIProvider(cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OpenAI provider: %w", err)
+		}
+		return provider, nil
+	})
+	Register("anthropic", func(cfg *config.Config, name string) (Provider, error) {
+		provider, err := newAnthropicProvider(cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Anthropic provider: %w", err)
+		}
+		return provider, nil
+	})
+	Register("gemini", func(cfg *config.Config, name string) (Provider, error) {
+		provider, err := NewGeminiProvider(cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Gemini provider: %w", err)
+		}
+		return provider, nil
+	})
+	Register("local", func(cfg *config.Config, name string) (Provider, error) {
+		provider, err := newLocalProvider(cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create local provider: %w", err)
+		}
+		return provider, nil
+	})
+	Register("openai-compatible", func(cfg *config.Config, name string) (Provider, error) {
+		provider, err := NewOpenAICompatibleProvider(cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OpenAI-compatible provider: %w", err)
+		}
+		return provider, nil
+	})
+	Register("azure", func(cfg *config.Config, name string) (Provider, error) {
+		provider, err := NewAzureProvider(cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure provider: %w", err)
+		}
+		return provider, nil
+	})
+	Register("exec", func(cfg *config.Config, name string) (Provider, error) {
+		provider, err := NewExecProvider(cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create exec provider: %w", err)
+		}
+		return provider, nil
+	})
+	Register("heuristic", func(cfg *config.Config, name string) (Provider, error) {
+		provider, err := NewHeuristicProvider(cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create heuristic provider: %w", err)
+		}
+		return provider, nil
+	})
+	Register("replay", func(cfg *config.Config, name string) (Provider, error) {
+		provider, err := NewReplayProvider(cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create replay provider: %w", err)
+		}
+		return provider, nil
+	})
+}
+
+// Register makes a provider type available under providerType. Registering
+// a type again replaces its factory.
+func Register(providerType string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[providerType] = factory
+}
+
+// RegisteredTypes returns the sorted names of all registered provider types
+func RegisteredTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for providerType := range registry {
+		types = append(types, providerType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewProvider creates the provider configured under name. The provider type
+// is taken from its type setting and defaults to the name itself, so the
+// built-in openai, anthropic and local providers need no type.
+func NewProvider(cfg *config.Config, name string) (Provider, error) {
+	providerConfig, err := cfg.GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	providerType := providerConfig.ProviderType(name)
+
+	registryMu.RLock()
+	factory, exists := registry[providerType]
+	registryMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unsupported AI provider: %s", providerType)
+	}
+
+	return factory(cfg, name)
+}
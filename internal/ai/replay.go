@@ -0,0 +1,335 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/anans9/ai-git/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// cassetteVersion is the version of the cassette file format
+const cassetteVersion = 1
+
+// Cassette holds recorded request and response pairs. Cassettes are YAML
+// files that can be committed next to tests and edited by hand.
+type Cassette struct {
+	Version      int           `yaml:"version"`
+	Interactions []Interaction `yaml:"interactions"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// Interaction is a recorded request and the response it received
+type Interaction struct {
+	Request  CassetteRequest  `yaml:"request"`
+	Response CassetteResponse `yaml:"response"`
+}
+
+// CassetteRequest is a recorded request. Provider and model are kept for
+// reference only and are not used for matching.
+type CassetteRequest struct {
+	Provider     string `yaml:"provider,omitempty"`
+	Model        string `yaml:"model,omitempty"`
+	SystemPrompt string `yaml:"system_prompt,omitempty"`
+	Prompt       string `yaml:"prompt"`
+	N            int    `yaml:"n,omitempty"`
+	Schema       string `yaml:"schema,omitempty"`
+}
+
+// CassetteResponse is a recorded response
+type CassetteResponse struct {
+	Content string   `yaml:"content"`
+	Choices []string `yaml:"choices,omitempty"`
+	Usage   Usage    `yaml:"usage,omitempty"`
+}
+
+var (
+	// indexLinePattern matches the blob hashes of a diff, which change with
+	// any unrelated edit to a file
+	indexLinePattern = regexp.MustCompile(`(?m)^index [0-9a-f]+\.\.[0-9a-f]+( \d+)?$`)
+	// blankLinesPattern matches runs of blank lines
+	blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// LoadCassette reads the cassette at path. A missing file gives an empty
+// cassette, ready for recording.
+func LoadCassette(path string) (*Cassette, error) {
+	cassette := &Cassette{Version: cassetteVersion, path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cassette, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	if cassette.Version > cassetteVersion {
+		return nil, fmt.Errorf("cassette %s has unsupported version %d", path, cassette.Version)
+	}
+
+	return cassette, nil
+}
+
+// Find returns the recorded response to a request matching req
+func (c *Cassette) Find(req Request) (CassetteResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := requestKey(newCassetteRequest(req, "", ""))
+	for _, interaction := range c.Interactions {
+		if requestKey(interaction.Request) == key {
+			return interaction.Response, true
+		}
+	}
+	return CassetteResponse{}, false
+}
+
+// Record adds an interaction, replacing any recorded for the same request,
+// and saves the cassette
+func (c *Cassette) Record(interaction Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := requestKey(interaction.Request)
+	replaced := false
+	for i := range c.Interactions {
+		if requestKey(c.Interactions[i].Request) == key {
+			c.Interactions[i] = interaction
+			replaced = true
+		}
+	}
+	if !replaced {
+		c.Interactions = append(c.Interactions, interaction)
+	}
+
+	return c.save()
+}
+
+func (c *Cassette) save() error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+
+	if dir := filepath.Dir(c.path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create cassette directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(c.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// newCassetteRequest returns the request as recorded. The replay prompt is
+// recorded when there is one, so that the recording matches other checkouts.
+func newCassetteRequest(req Request, provider, model string) CassetteRequest {
+	prompt := req.Prompt
+	if req.ReplayPrompt != "" {
+		prompt = req.ReplayPrompt
+	}
+
+	return CassetteRequest{
+		Provider:     provider,
+		Model:        model,
+		SystemPrompt: req.SystemPrompt,
+		Prompt:       prompt,
+		N:            req.N,
+		Schema:       string(req.Schema),
+	}
+}
+
+// requestKey identifies a request by its normalized prompts, number of
+// choices and schema
+func requestKey(req CassetteRequest) string {
+	n := req.N
+	if n < 1 {
+		n = 1
+	}
+
+	schema := req.Schema
+	var compact bytes.Buffer
+	if json.Compact(&compact, []byte(schema)) == nil {
+		schema = compact.String()
+	}
+
+	return strings.Join([]string{
+		normalizePrompt(req.SystemPrompt),
+		normalizePrompt(req.Prompt),
+		fmt.Sprint(n),
+		schema,
+	}, "\x00")
+}
+
+// normalizePrompt removes differences that do not change the meaning of a
+// prompt: trailing whitespace, extra blank lines and blob hashes in diffs
+func normalizePrompt(prompt string) string {
+	prompt = strings.ReplaceAll(prompt, "\r\n", "\n")
+	prompt = indexLinePattern.ReplaceAllString(prompt, "index")
+
+	lines := strings.Split(prompt, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	prompt = strings.Join(lines, "\n")
+
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(prompt, "\n\n"))
+}
+
+// ReplayProvider implements the Provider interface by serving responses
+// recorded in a cassette, for tests that must run without network access
+type ReplayProvider struct {
+	name     string
+	model    string
+	cassette *Cassette
+}
+
+// NewReplayProvider creates a provider that replays the cassette configured
+// under name
+func NewReplayProvider(cfg *config.Config, name string) (*ReplayProvider, error) {
+	providerConfig, err := cfg.GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if providerConfig.Cassette == "" {
+		return nil, fmt.Errorf("cassette is required for replay provider %s", name)
+	}
+
+	cassette, err := LoadCassette(providerConfig.Cassette)
+	if err != nil {
+		return nil, err
+	}
+
+	model := providerConfig.Model
+	if model == "" {
+		model = cfg.AI.Model
+	}
+
+	return &ReplayProvider{
+		name:     name,
+		model:    model,
+		cassette: cassette,
+	}, nil
+}
+
+// Complete returns the recorded response to req. Requests that were not
+// recorded fail, so that tests notice when prompts change.
+func (p *ReplayProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	recorded, ok := p.cassette.Find(req)
+	if !ok {
+		return Response{}, fmt.Errorf("no recorded response in %s for this request; record it again with --record", p.cassette.path)
+	}
+
+	return Response{
+		Content: recorded.Content,
+		Choices: recorded.Choices,
+		Usage:   recorded.Usage,
+	}, nil
+}
+
+// Stream returns the recorded response to req as a single token
+func (p *ReplayProvider) Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error) {
+	resp, err := p.Complete(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if onToken != nil && resp.Content != "" {
+		onToken(resp.Content)
+	}
+	return resp, nil
+}
+
+func (p *ReplayProvider) Name() string {
+	return p.name
+}
+
+// Model returns the model used for token budgets
+func (p *ReplayProvider) Model() string {
+	return p.model
+}
+
+// recordingProvider records the requests and responses of a provider in a
+// cassette
+type recordingProvider struct {
+	Provider
+	config   *config.Config
+	cassette *Cassette
+}
+
+// newRecordingProviders wraps providers so that their responses are recorded
+// in the cassette at path
+func newRecordingProviders(cfg *config.Config, path string, providers []Provider) ([]Provider, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+
+	recording := make([]Provider, 0, len(providers))
+	for _, provider := range providers {
+		recording = append(recording, &recordingProvider{Provider: provider, config: cfg, cassette: cassette})
+	}
+	return recording, nil
+}
+
+func (p *recordingProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	resp, err := p.Provider.Complete(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+	if err := p.record(req, resp); err != nil {
+		return Response{}, err
+	}
+	return resp, nil
+}
+
+func (p *recordingProvider) Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error) {
+	resp, err := p.Provider.Stream(ctx, req, onToken)
+	if err != nil {
+		return Response{}, err
+	}
+	if err := p.record(req, resp); err != nil {
+		return Response{}, err
+	}
+	return resp, nil
+}
+
+// Model returns the model of the recorded provider
+func (p *recordingProvider) Model() string {
+	if provider, ok := p.Provider.(modelProvider); ok {
+		return provider.Model()
+	}
+	return p.config.ModelFor(p.Name())
+}
+
+func (p *recordingProvider) record(req Request, resp Response) error {
+	err := p.cassette.Record(Interaction{
+		Request: newCassetteRequest(req, p.Name(), p.Model()),
+		Response: CassetteResponse{
+			Content: resp.Content,
+			Choices: resp.Choices,
+			Usage:   resp.Usage,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package ai
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anans9/ai-git/internal/config"
+	"github.com/anans9/ai-git/internal/prompt"
+)
+
+func TestNormalizePrompt(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"line endings", "a\r\nb", "a\nb"},
+		{"trailing whitespace", "a  \nb\t", "a\nb"},
+		{"blank lines", "a\n\n\n\nb", "a\n\nb"},
+		{"index lines", "index 1a2b3c4..5d6e7f8 100644\n+x", "index 9f8e7d6..c5b4a39 100644\n+x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if a, b := normalizePrompt(tt.a), normalizePrompt(tt.b); a != b {
+				t.Errorf("normalizePrompt() = %q and %q, want them equal", a, b)
+			}
+		})
+	}
+}
+
+// TestReplayPromptDefaultTemplate checks that the default commit prompt is
+// matched across branches and histories
+func TestReplayPromptDefaultTemplate(t *testing.T) {
+	config.SetDefaults()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	client := newStubClient(&stubProvider{name: "stub"})
+	client.config = cfg
+
+	contexts := []prompt.Context{
+		{Branch: "feature/ABC-1-login", Ticket: "ABC-1", RecentCommits: []string{"feat: add login", "fix: handle nil user"}},
+		{Branch: "1a2b3c4"},
+		{},
+	}
+
+	var replays []string
+	for _, data := range contexts {
+		client.SetPromptContext(data)
+		rendered, replay, err := client.commitPrompt("+x")
+		if err != nil {
+			t.Fatalf("commitPrompt() error = %v", err)
+		}
+		if data.Branch != "" && !strings.Contains(rendered, data.Branch) {
+			t.Errorf("rendered prompt %q does not name the branch %s", rendered, data.Branch)
+		}
+		replays = append(replays, replay)
+	}
+
+	for _, replay := range replays[1:] {
+		if replay != replays[0] {
+			t.Errorf("replay prompts differ:\n%s\n---\n%s", replays[0], replay)
+		}
+	}
+}
+
+func TestRequestKey(t *testing.T) {
+	base := CassetteRequest{Prompt: "describe", Schema: `{"type": "object"}`}
+
+	same := []CassetteRequest{
+		{Prompt: "describe  ", Schema: `{"type":"object"}`},
+		{Prompt: "describe", Schema: `{"type": "object"}`, N: 1},
+		{Prompt: "describe", Schema: `{"type": "object"}`, Provider: "openai", Model: "gpt-4"},
+	}
+	for _, req := range same {
+		if requestKey(req) != requestKey(base) {
+			t.Errorf("requestKey(%+v) differs from requestKey(%+v)", req, base)
+		}
+	}
+
+	different := []CassetteRequest{
+		{Prompt: "describe it", Schema: base.Schema},
+		{Prompt: "describe", Schema: base.Schema, N: 3},
+		{Prompt: "describe"},
+		{Prompt: "describe", Schema: base.Schema, SystemPrompt: "be brief"},
+	}
+	for _, req := range different {
+		if requestKey(req) == requestKey(base) {
+			t.Errorf("requestKey(%+v) matches requestKey(%+v)", req, base)
+		}
+	}
+}
+
+func TestReplayProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("LoadCassette() error = %v", err)
+	}
+	req := Request{SystemPrompt: "system", Prompt: "Branch: main\n\nGit diff:\n+x", ReplayPrompt: "Git diff:\n+x"}
+	err = cassette.Record(Interaction{
+		Request:  newCassetteRequest(req, "openai", "gpt-4"),
+		Response: CassetteResponse{Content: "feat: add x"},
+	})
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	cfg := &config.Config{AI: config.AIConfig{
+		Provider: "replay",
+		Providers: map[string]config.AIProvider{
+			"replay": {Type: "replay", Cassette: path, Enabled: true},
+		},
+	}}
+	provider, err := NewProvider(cfg, "replay")
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+
+	// A detached checkout renders a different branch
+	req.Prompt = "Branch: 1a2b3c4\n\nGit diff:\n+x"
+	resp, err := provider.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Content != "feat: add x" {
+		t.Errorf("Content = %q, want %q", resp.Content, "feat: add x")
+	}
+
+	req.Prompt = "Git diff:\n+y"
+	req.ReplayPrompt = "Git diff:\n+y"
+	if _, err := provider.Complete(context.Background(), req); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("Complete() error = %v, want a missing recording", err)
+	}
+}
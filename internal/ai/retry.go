@@ -0,0 +1,306 @@
+package ai
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// retryPolicy describes how failed provider requests are retried
+type retryPolicy struct {
+	maxAttempts  int
+	initialDelay time.Duration
+	maxDelay     time.Duration
+}
+
+// newRetryPolicy builds a retry policy from the ai.retry configuration
+func newRetryPolicy(cfg config.RetryConfig) (retryPolicy, error) {
+	initialDelay, maxDelay, err := cfg.Delays()
+	if err != nil {
+		return retryPolicy{}, err
+	}
+
+	policy := retryPolicy{
+		maxAttempts:  cfg.MaxAttempts,
+		initialDelay: initialDelay,
+		maxDelay:     maxDelay,
+	}
+	if policy.maxAttempts < 1 {
+		policy.maxAttempts = 1
+	}
+
+	return policy, nil
+}
+
+// backoff returns the delay before the given retry attempt (1-based) using
+// exponential backoff with jitter
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := p.initialDelay
+	for i := 1; i < attempt && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	if p.maxDelay > 0 && delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	// Pick a random delay in [delay/2, delay] so concurrent clients spread out
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryTransport retries requests that failed with a transient error such
+// as a rate limit, an overloaded server or a connection that could not be
+// made. Each attempt gets its own timeout so retries are not starved by the
+// time spent on earlier attempts and backoff.
+type retryTransport struct {
+	base    http.RoundTripper
+	policy  retryPolicy
+	timeout time.Duration
+}
+
+// newHTTPClient creates an HTTP client for requests to the provider
+// configured under name that uses the shared transport of ai.network and
+// applies the configured retry policy. The provider's timeout setting
+// overrides the default timeout, which limits each attempt including reading
+// the response; a zero timeout means no client-side timeout.
+func newHTTPClient(cfg *config.Config, name string, timeout time.Duration) (*http.Client, error) {
+	policy, err := newRetryPolicy(cfg.AI.Retry)
+	if err != nil {
+		return nil, err
+	}
+
+	if providerConfig, err := cfg.GetProvider(name); err == nil {
+		if timeout, err = providerConfig.RequestTimeout(timeout); err != nil {
+			return nil, err
+		}
+	}
+
+	transport, err := sharedTransport(cfg.AI.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &retryTransport{
+			base:    transport,
+			policy:  policy,
+			timeout: timeout,
+		},
+	}, nil
+}
+
+// untimedClient returns a copy of client whose requests are retried but not
+// limited by the per-attempt timeout, for transfers that may legitimately
+// take longer and are cancelled through their context instead
+func untimedClient(client *http.Client) *http.Client {
+	untimed := *client
+	if transport, ok := client.Transport.(*retryTransport); ok {
+		withoutTimeout := *transport
+		withoutTimeout.timeout = 0
+		untimed.Transport = &withoutTimeout
+	}
+	untimed.Timeout = 0
+	return &untimed
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("cannot retry request with a non-rewindable body")
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, written, err := t.attempt(req)
+		if attempt >= t.policy.maxAttempts || !shouldRetry(req.Context(), resp, written, err) {
+			return resp, err
+		}
+
+		delay := t.policy.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := retryAfterDelay(resp); ok {
+				// Give up rather than wait longer than the configured maximum
+				if t.policy.maxDelay > 0 && retryAfter > t.policy.maxDelay {
+					return resp, err
+				}
+				delay = retryAfter
+			}
+		}
+
+		// A retry after the operation's deadline cannot succeed, so report
+		// this failure rather than wait out the deadline
+		if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) <= delay {
+			return resp, err
+		}
+
+		if resp != nil {
+			// Drain the body so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends req once within the per-attempt timeout and reports whether
+// the request was written to the connection before it failed
+func (t *retryTransport) attempt(req *http.Request) (*http.Response, bool, error) {
+	var written atomic.Bool
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) { written.Store(true) },
+	})
+
+	cancel := context.CancelFunc(func() {})
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if t.timeout > 0 && errors.Is(err, context.DeadlineExceeded) && req.Context().Err() == nil {
+			err = fmt.Errorf("request timed out after %s: %w", t.timeout, err)
+		}
+		return nil, written.Load(), err
+	}
+
+	// The timeout also covers reading the body, so it ends when the body is closed
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, written.Load(), nil
+}
+
+// cancelBody releases the context of an attempt when its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// shouldRetry reports whether a request failed in a way that is safe to
+// retry. Transport errors are retried only when the request never reached
+// the provider, so a request that may have been processed is not sent, and
+// billed, twice; otherwise the provider must have rejected it before doing
+// any work.
+func shouldRetry(ctx context.Context, resp *http.Response, written bool, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		// A request that was never written, such as one whose connection was
+		// refused or whose TLS handshake failed, is safe to send again unless
+		// the server's certificate was rejected
+		var certErr *tls.CertificateVerificationError
+		return !written && !errors.As(err, &certErr)
+	}
+
+	// Providers may say explicitly whether a retry is worthwhile
+	switch strings.ToLower(resp.Header.Get("x-should-retry")) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	return isRetryableStatus(resp.StatusCode)
+}
+
+// isRetryableStatus reports whether an HTTP status denotes a transient failure
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529: // Anthropic: overloaded
+		return true
+	}
+	return false
+}
+
+// retryAfterDelay extracts how long the provider asked us to wait from the
+// Retry-After header or, for rate limited requests, the provider-specific
+// rate limit headers
+func retryAfterDelay(resp *http.Response) (time.Duration, bool) {
+	header := resp.Header
+
+	if value := header.Get("retry-after-ms"); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return nonNegative(time.Until(date)), true
+		}
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	// OpenAI reports resets as durations such as "1s" or "6m0s"
+	for _, name := range []string{"x-ratelimit-reset-requests", "x-ratelimit-reset-tokens"} {
+		if value := header.Get(name); value != "" {
+			if delay, err := time.ParseDuration(value); err == nil {
+				return nonNegative(delay), true
+			}
+		}
+	}
+
+	// Anthropic reports resets as RFC 3339 timestamps
+	for _, name := range []string{"anthropic-ratelimit-requests-reset", "anthropic-ratelimit-tokens-reset"} {
+		if value := header.Get(name); value != "" {
+			if reset, err := time.Parse(time.RFC3339, value); err == nil {
+				return nonNegative(time.Until(reset)), true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,252 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestRetryClient returns a client that retries up to three times with
+// short delays and limits each attempt to timeout
+func newTestRetryClient(t *testing.T, timeout time.Duration) *http.Client {
+	t.Helper()
+
+	return &http.Client{Transport: &retryTransport{
+		base:    http.DefaultTransport,
+		policy:  retryPolicy{maxAttempts: 3, initialDelay: time.Millisecond, maxDelay: 50 * time.Millisecond},
+		timeout: timeout,
+	}}
+}
+
+// countingServer answers attempt n (1-based) with handler and counts attempts
+func countingServer(t *testing.T, handler func(w http.ResponseWriter, attempt int)) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		handler(w, int(attempts.Add(1)))
+	}))
+	t.Cleanup(server.Close)
+	return server, &attempts
+}
+
+func post(t *testing.T, client *http.Client, url string) (*http.Response, error) {
+	t.Helper()
+	return client.Post(url, "application/json", strings.NewReader(`{"prompt":"x"}`))
+}
+
+func TestRetryRateLimit(t *testing.T) {
+	server, attempts := countingServer(t, func(w http.ResponseWriter, attempt int) {
+		if attempt == 1 {
+			w.Header().Set("Retry-After", "0.02")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	start := time.Now()
+	resp, err := post(t, newTestRetryClient(t, 0), server.URL)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || attempts.Load() != 2 {
+		t.Errorf("status %d after %d attempts, want 200 after 2", resp.StatusCode, attempts.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("retried after %s, want the 20ms asked for by Retry-After", elapsed)
+	}
+}
+
+func TestRetryAfterBeyondMaxDelay(t *testing.T) {
+	server, attempts := countingServer(t, func(w http.ResponseWriter, attempt int) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	resp, err := post(t, newTestRetryClient(t, 0), server.URL)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests || attempts.Load() != 1 {
+		t.Errorf("status %d after %d attempts, want 429 after 1", resp.StatusCode, attempts.Load())
+	}
+}
+
+func TestRetryServerError(t *testing.T) {
+	server, attempts := countingServer(t, func(w http.ResponseWriter, attempt int) {
+		if attempt < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	resp, err := post(t, newTestRetryClient(t, 0), server.URL)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || attempts.Load() != 3 {
+		t.Errorf("status %d after %d attempts, want 200 after 3", resp.StatusCode, attempts.Load())
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header string
+	}{
+		{"bad request", http.StatusBadRequest, ""},
+		{"x-should-retry false", http.StatusServiceUnavailable, "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, attempts := countingServer(t, func(w http.ResponseWriter, attempt int) {
+				if tt.header != "" {
+					w.Header().Set("x-should-retry", tt.header)
+				}
+				w.WriteHeader(tt.status)
+			})
+
+			resp, err := post(t, newTestRetryClient(t, 0), server.URL)
+			if err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status || attempts.Load() != 1 {
+				t.Errorf("status %d after %d attempts, want %d after 1", resp.StatusCode, attempts.Load(), tt.status)
+			}
+		})
+	}
+}
+
+func TestRetryBackoffBeyondDeadline(t *testing.T) {
+	server, attempts := countingServer(t, func(w http.ResponseWriter, attempt int) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	client := newTestRetryClient(t, 0)
+	client.Transport.(*retryTransport).policy = retryPolicy{maxAttempts: 3, initialDelay: time.Second, maxDelay: time.Minute}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v, want the server's answer", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable || attempts.Load() != 1 {
+		t.Errorf("status %d after %d attempts, want 503 after 1", resp.StatusCode, attempts.Load())
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("returned after %s, want no wait for a retry past the deadline", elapsed)
+	}
+}
+
+func TestRetryUnsentRequest(t *testing.T) {
+	// A closed listener refuses connections, so the request is never sent
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + listener.Addr().String()
+	listener.Close()
+
+	var dials atomic.Int32
+	client := newTestRetryClient(t, 0)
+	client.Transport.(*retryTransport).base = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+
+	if _, err := post(t, client, url); err == nil {
+		t.Fatal("Post() error = nil, want a refused connection")
+	}
+	if dials.Load() != 3 {
+		t.Errorf("dialed %d times, want 3", dials.Load())
+	}
+}
+
+func TestRetrySentRequestTimeout(t *testing.T) {
+	server, attempts := countingServer(t, func(w http.ResponseWriter, attempt int) {
+		time.Sleep(200 * time.Millisecond)
+	})
+
+	_, err := post(t, newTestRetryClient(t, 50*time.Millisecond), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+		t.Fatalf("Post() error = %v, want a timeout", err)
+	}
+	// The server may have processed the request, so it is not sent again
+	if attempts.Load() != 1 {
+		t.Errorf("sent %d times, want 1", attempts.Load())
+	}
+}
+
+func TestRetryTimeoutCoversBody(t *testing.T) {
+	server, _ := countingServer(t, func(w http.ResponseWriter, attempt int) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(" rest"))
+	})
+
+	resp, err := post(t, newTestRetryClient(t, 50*time.Millisecond), server.URL)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Error("reading a body that outlives the timeout succeeded, want an error")
+	}
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		want   time.Duration
+		ok     bool
+	}{
+		{"seconds", 503, http.Header{"Retry-After": {"2"}}, 2 * time.Second, true},
+		{"milliseconds", 429, http.Header{"Retry-After-Ms": {"150"}}, 150 * time.Millisecond, true},
+		{"openai reset", 429, http.Header{"X-Ratelimit-Reset-Requests": {"1.5s"}}, 1500 * time.Millisecond, true},
+		{"reset without rate limit", 503, http.Header{"X-Ratelimit-Reset-Requests": {"1s"}}, 0, false},
+		{"none", 503, http.Header{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfterDelay(&http.Response{StatusCode: tt.status, Header: tt.header})
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("retryAfterDelay() = %s, %v, want %s, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Review finding severities, from least to most severe
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// Finding is a problem reported by a code review
+type Finding struct {
+	File       string
+	Line       int
+	Severity   string
+	Message    string
+	Suggestion string
+}
+
+// reviewSystemPrompt replaces the configured system prompt, which asks for
+// commit messages, when reviewing code
+const reviewSystemPrompt = `You are an expert software engineer reviewing code changes.
+Report only real problems, precisely and briefly.`
+
+// reviewSchema is the JSON schema of a review response
+var reviewSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "findings": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "file": {"type": "string"},
+          "line": {"type": "integer", "description": "Line number in the new version of the file"},
+          "severity": {"type": "string", "enum": ["low", "medium", "high"]},
+          "message": {"type": "string"},
+          "suggestion": {"type": "string"}
+        },
+        "required": ["file", "severity", "message"]
+      }
+    }
+  },
+  "required": ["findings"]
+}`)
+
+// reviewJSONInstructions is appended to the code review prompt
+const reviewJSONInstructions = `
+
+Respond with only a JSON object, without markdown, of the form:
+{"findings": [{"file": "path/to/file", "line": 42, "severity": "high", "message": "what is wrong", "suggestion": "how to fix it"}]}
+The line is the line number in the new version of the file. Severity is low, medium or high.
+Respond with {"findings": []} if there are no problems.`
+
+// reviewMaxTokens is the smallest response size allowed for a review
+const reviewMaxTokens = 1024
+
+// SeverityRank orders severities from 1 (low) to 3 (high). Unknown
+// severities rank 0.
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityLow:
+		return 1
+	case SeverityMedium:
+		return 2
+	case SeverityHigh:
+		return 3
+	}
+	return 0
+}
+
+// Review reviews the parts of a diff, usually one per file, using the code
+// review prompt. A diff that exceeds the token budget is reviewed in chunks
+// concurrently. Findings are sorted by file and line.
+func (c *Client) Review(ctx context.Context, parts []string) ([]Finding, error) {
+	reviewPrompt := c.config.Templates.Prompts.CodeReview
+	if reviewPrompt == "" {
+		return nil, fmt.Errorf("no code review prompt configured (templates.prompts.code_review)")
+	}
+
+	// Render the template once without the diff to check it and to size the
+	// chunks
+	rendered, _, err := c.renderPrompt(reviewPrompt, "")
+	if err != nil {
+		return nil, err
+	}
+
+	responseTokens := c.responseTokens(reviewMaxTokens)
+	budget := c.budget(c.config.AI.Summarize.MaxTokens, reviewSystemPrompt+rendered+reviewJSONInstructions, responseTokens)
+
+	chunks := []string{strings.Join(parts, "\n")}
+	if c.EstimateTokens(chunks[0]) > budget {
+		chunks = chunkParts(parts, budget, c.EstimateTokens)
+	}
+
+	responses, err := c.completeChunks(ctx, chunks, func(chunk string) Request {
+		chunkPrompt, replayPrompt, _ := c.renderPrompt(reviewPrompt, chunk)
+		req := c.NewRequest(chunkPrompt + reviewJSONInstructions)
+		req.Kind = KindReview
+		req.ReplayPrompt = replayPrompt + reviewJSONInstructions
+		req.SystemPrompt = reviewSystemPrompt
+		req.MaxTokens = responseTokens
+		req.Schema = reviewSchema
+		return req
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, response := range responses {
+		parsed, err := ParseFindings(response)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, parsed...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+
+	return findings, nil
+}
+
+// reviewFinding is a finding as written by a model. Line and severity are
+// decoded leniently.
+type reviewFinding struct {
+	File       string          `json:"file"`
+	Path       string          `json:"path"`
+	Line       json.RawMessage `json:"line"`
+	Severity   string          `json:"severity"`
+	Message    string          `json:"message"`
+	Suggestion string          `json:"suggestion"`
+}
+
+// ParseFindings parses a review response: an object with a findings list, or
+// the list itself, possibly wrapped in markdown
+func ParseFindings(text string) ([]Finding, error) {
+	text = strings.TrimSpace(text)
+
+	var raw []reviewFinding
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("failed to parse review: no JSON in response")
+	}
+	text = text[start : end+1]
+
+	if strings.HasPrefix(text, "[") {
+		if err := json.Unmarshal([]byte(text), &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse review: %w", err)
+		}
+	} else {
+		var response map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(text), &response); err != nil {
+			return nil, fmt.Errorf("failed to parse review: %w", err)
+		}
+		list := response["findings"]
+		if list == nil {
+			list = response["issues"]
+		}
+		if list != nil {
+			if err := json.Unmarshal(list, &raw); err != nil {
+				return nil, fmt.Errorf("failed to parse review: %w", err)
+			}
+		}
+	}
+
+	findings := make([]Finding, 0, len(raw))
+	for _, finding := range raw {
+		if strings.TrimSpace(finding.Message) == "" {
+			continue
+		}
+		file := finding.File
+		if file == "" {
+			file = finding.Path
+		}
+		findings = append(findings, Finding{
+			File:       strings.TrimSpace(file),
+			Line:       parseLine(finding.Line),
+			Severity:   normalizeSeverity(finding.Severity),
+			Message:    strings.TrimSpace(finding.Message),
+			Suggestion: strings.TrimSpace(finding.Suggestion),
+		})
+	}
+
+	return findings, nil
+}
+
+// parseLine reads a line number given as a number or as a string such as
+// "42" or "42-45". Unknown lines are 0.
+func parseLine(raw json.RawMessage) int {
+	var line int
+	if json.Unmarshal(raw, &line) == nil {
+		return line
+	}
+
+	var text string
+	if json.Unmarshal(raw, &text) != nil {
+		return 0
+	}
+	text = strings.TrimSpace(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(text)), "L"))
+	end := 0
+	for end < len(text) && text[end] >= '0' && text[end] <= '9' {
+		end++
+	}
+	line, _ = strconv.Atoi(text[:end])
+	return line
+}
+
+// normalizeSeverity maps the severity names models use to low, medium or
+// high
+func normalizeSeverity(severity string) string {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "high", "critical", "blocker", "error", "severe":
+		return SeverityHigh
+	case "medium", "major", "warning", "moderate":
+		return SeverityMedium
+	default:
+		return SeverityLow
+	}
+}
@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxStreamLineSize bounds a single SSE or NDJSON line
+const maxStreamLineSize = 1024 * 1024
+
+// errStreamDone is returned by stream callbacks to stop reading early
+var errStreamDone = errors.New("stream done")
+
+// readSSE reads a server-sent event stream and calls fn with the data
+// payload of every event until the stream ends or fn returns an error
+func readSSE(r io.Reader, fn func(data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+
+	var data []string
+	flush := func() error {
+		if len(data) == 0 {
+			return nil
+		}
+		payload := strings.Join(data, "\n")
+		data = data[:0]
+		if payload == "[DONE]" {
+			return errStreamDone
+		}
+		return fn(payload)
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A blank line terminates the current event
+		if line == "" {
+			if err := flush(); err != nil {
+				return ignoreStreamDone(err)
+			}
+			continue
+		}
+
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return ignoreStreamDone(flush())
+}
+
+// readNDJSON reads a newline-delimited JSON stream and calls fn with every
+// non-empty line until the stream ends or fn returns an error
+func readNDJSON(r io.Reader, fn func(line []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return ignoreStreamDone(err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return nil
+}
+
+func ignoreStreamDone(err error) error {
+	if errors.Is(err, errStreamDone) {
+		return nil
+	}
+	return err
+}
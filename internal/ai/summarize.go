@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// summarySystemPrompt replaces the configured system prompt, which asks for
+// commit messages, when summarizing parts of a diff
+const summarySystemPrompt = `You are an expert software engineer summarizing code changes.
+Describe what changed and why, accurately and briefly.`
+
+// summaryPrompt asks for a summary of one part of a diff
+const summaryPrompt = `Summarize the following part of a git diff so that a commit message can be written from it.
+Use a few short bullet points. Mention every file and the purpose of its changes.
+
+{diff}
+
+Summary:`
+
+// maxSummaryRounds bounds how often summaries are summarized again when
+// they still exceed the token budget
+const maxSummaryRounds = 3
+
+// NeedsSummary reports whether parts of a diff together exceed the diff
+// budget of the commit prompt
+func (c *Client) NeedsSummary(parts []string) bool {
+	return c.config.AI.Summarize.Enabled && c.EstimateTokens(strings.Join(parts, "\n")) > c.DiffBudget()
+}
+
+// SummarizeDiff reduces the parts of a diff, usually one per file, to text
+// that fits in the token budget. Parts are grouped into chunks that are
+// summarized concurrently, and the summaries are reduced again until they
+// fit, so that the result covers every part.
+func (c *Client) SummarizeDiff(ctx context.Context, parts []string) (string, error) {
+	for round := 0; ; round++ {
+		content := strings.Join(parts, "\n")
+		if !c.NeedsSummary(parts) {
+			return content, nil
+		}
+		if round == maxSummaryRounds {
+			return c.TruncateTokens(content, c.DiffBudget()), nil
+		}
+
+		summaries, err := c.summarizeChunks(ctx, chunkParts(parts, c.chunkBudget(), c.EstimateTokens))
+		if err != nil {
+			return "", err
+		}
+		parts = summaries
+	}
+}
+
+// summarizeChunks summarizes each chunk. Summaries are returned in chunk
+// order.
+func (c *Client) summarizeChunks(ctx context.Context, chunks []string) ([]string, error) {
+	return c.completeChunks(ctx, chunks, func(chunk string) Request {
+		req := c.NewRequest(strings.ReplaceAll(summaryPrompt, "{diff}", chunk))
+		req.Kind = KindSummary
+		req.SystemPrompt = summarySystemPrompt
+		req.MaxTokens = c.config.AI.Summarize.SummaryTokens
+		return req
+	})
+}
+
+// completeChunks sends the request built for each chunk, running up to the
+// configured number of requests at once. Responses are returned in chunk
+// order, and the first failure cancels the remaining requests.
+func (c *Client) completeChunks(ctx context.Context, chunks []string, newRequest func(chunk string) Request) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+	concurrency := c.config.AI.Summarize.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	limit := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			if ctx.Err() != nil {
+				errs[i] = ctx.Err()
+				return
+			}
+
+			results[i], errs[i] = text(c.Complete(ctx, newRequest(chunk)))
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	// Report the failure that caused the others to be cancelled
+	for _, err := range errs {
+		if err != nil && err != context.Canceled {
+			return nil, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// chunkParts groups consecutive parts into chunks of at most limit tokens.
+// A part that exceeds the limit on its own is truncated.
+func chunkParts(parts []string, limit int, estimate func(string) int) []string {
+	var chunks []string
+	var current strings.Builder
+	tokens := 0
+
+	for _, part := range parts {
+		part = truncateTokens(part, limit, estimate)
+		partTokens := estimate(part)
+
+		if current.Len() > 0 && tokens+partTokens > limit {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			tokens = 0
+		}
+
+		current.WriteString(part)
+		current.WriteString("\n")
+		tokens += partTokens
+	}
+
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+
+	return chunks
+}
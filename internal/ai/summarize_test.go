@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// summaryProvider answers every request with content and counts requests
+type summaryProvider struct {
+	content string
+	calls   atomic.Int32
+}
+
+func (p *summaryProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	p.calls.Add(1)
+	return Response{Content: p.content}, nil
+}
+
+func (p *summaryProvider) Stream(ctx context.Context, req Request, onToken TokenHandler) (Response, error) {
+	return p.Complete(ctx, req)
+}
+
+func (p *summaryProvider) Name() string {
+	return "summary"
+}
+
+func newSummaryClient(provider Provider) *Client {
+	client := newStubClient(provider)
+	client.config.AI.Summarize = config.SummarizeConfig{
+		Enabled:       true,
+		MaxTokens:     minBudget,
+		ChunkTokens:   minBudget,
+		SummaryTokens: 50,
+		Concurrency:   2,
+	}
+	return client
+}
+
+// diffParts returns count parts of roughly size tokens each
+func diffParts(count, size int) []string {
+	parts := make([]string, count)
+	for i := range parts {
+		parts[i] = fmt.Sprintf("diff --git a/file%d.go b/file%d.go\n%s", i, i, strings.Repeat("+line of code\n", size/6))
+	}
+	return parts
+}
+
+func TestSummarizeDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		parts     []string
+		summary   string
+		wantCalls int32
+		want      func(t *testing.T, result string)
+	}{
+		{
+			name:      "fits",
+			parts:     diffParts(2, 50),
+			wantCalls: 0,
+			want: func(t *testing.T, result string) {
+				if !strings.Contains(result, "file0.go") || !strings.Contains(result, "file1.go") {
+					t.Errorf("result = %q, want the parts unchanged", result)
+				}
+			},
+		},
+		{
+			name:      "one round",
+			parts:     diffParts(10, 100),
+			summary:   "- changed a file",
+			wantCalls: 5,
+			want: func(t *testing.T, result string) {
+				if got := strings.Count(result, "- changed a file"); got != 5 {
+					t.Errorf("result has %d summaries, want one per chunk of two parts", got)
+				}
+			},
+		},
+		{
+			name:      "truncated after max rounds",
+			parts:     diffParts(10, 100),
+			summary:   strings.Repeat("- a summary that is far too long\n", 30),
+			wantCalls: 5 + 5 + 5,
+			want: func(t *testing.T, result string) {
+				if !strings.HasSuffix(result, "... (truncated)\n") {
+					t.Errorf("result = %q, want it truncated", result)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &summaryProvider{content: tt.summary}
+			client := newSummaryClient(provider)
+
+			result, err := client.SummarizeDiff(context.Background(), tt.parts)
+			if err != nil {
+				t.Fatalf("SummarizeDiff() error = %v", err)
+			}
+			if provider.calls.Load() != tt.wantCalls {
+				t.Errorf("sent %d requests, want %d", provider.calls.Load(), tt.wantCalls)
+			}
+			if tokens := client.EstimateTokens(result); tokens > client.DiffBudget() {
+				t.Errorf("result has %d tokens, want at most %d", tokens, client.DiffBudget())
+			}
+			tt.want(t, result)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"strings"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// minBudget is the smallest token budget given to a diff, even when the
+// model's context window is nearly used up by the prompt and response
+const minBudget = 256
+
+// modelProvider is implemented by providers that report the model they use
+type modelProvider interface {
+	Model() string
+}
+
+// Model returns the model of the configured provider
+func (c *Client) Model() string {
+	if provider, ok := c.providers[0].(modelProvider); ok {
+		return provider.Model()
+	}
+	return c.config.ModelFor(c.providers[0].Name())
+}
+
+// ModelInfo returns the limits of the configured provider's model
+func (c *Client) ModelInfo() (config.ModelInfo, bool) {
+	return c.config.LookupModel(c.Model())
+}
+
+// EstimateTokens estimates the number of tokens in text using the tokenizer
+// of the configured provider's model
+func (c *Client) EstimateTokens(text string) int {
+	return estimateTokens(c.config, c.Model(), text)
+}
+
+// DiffBudget returns the number of tokens a diff may use in the commit
+// prompt: the summarize max_tokens, limited to what the model's context
+// window leaves for it after the rest of the prompt and the response
+func (c *Client) DiffBudget() int {
+	commitPrompt, _, err := c.commitPrompt("")
+	if err != nil {
+		// Generation reports the template error; the raw template is close
+		// enough for the budget
+		commitPrompt = c.config.Templates.Prompts.CommitMessage
+	}
+	return c.budget(c.config.AI.Summarize.MaxTokens, c.config.AI.SystemPrompt+commitPrompt, c.config.AI.MaxTokens)
+}
+
+// chunkBudget returns the number of tokens a chunk of a diff may use in a
+// summary request
+func (c *Client) chunkBudget() int {
+	return c.budget(c.config.AI.Summarize.ChunkTokens, summarySystemPrompt+summaryPrompt, c.config.AI.Summarize.SummaryTokens)
+}
+
+func (c *Client) budget(limit int, prompt string, response int) int {
+	info, ok := c.ModelInfo()
+	if ok && info.ContextWindow > 0 {
+		available := info.ContextWindow - response - c.EstimateTokens(prompt)
+		if available < limit {
+			limit = available
+		}
+	}
+
+	if limit < minBudget {
+		limit = minBudget
+	}
+	return limit
+}
+
+// responseTokens returns the configured max_tokens, raised to at least
+// minimum but not beyond the model's output limit
+func (c *Client) responseTokens(minimum int) int {
+	tokens := c.config.AI.MaxTokens
+	if tokens < minimum {
+		tokens = minimum
+		if info, ok := c.ModelInfo(); ok && info.MaxOutput > 0 && info.MaxOutput < tokens {
+			tokens = info.MaxOutput
+		}
+	}
+	return tokens
+}
+
+// TruncateTokens shortens text to at most limit tokens, cutting at a line
+// boundary
+func (c *Client) TruncateTokens(text string, limit int) string {
+	return truncateTokens(text, limit, c.EstimateTokens)
+}
+
+func truncateTokens(text string, limit int, estimate func(string) int) string {
+	if estimate(text) <= limit {
+		return text
+	}
+
+	const marker = "... (truncated)\n"
+	budget := limit - estimate(marker)
+
+	var result strings.Builder
+	used := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineTokens := estimate(line)
+		if used+lineTokens > budget {
+			break
+		}
+		result.WriteString(line)
+		used += lineTokens
+	}
+
+	if result.Len() > 0 && !strings.HasSuffix(result.String(), "\n") {
+		result.WriteString("\n")
+	}
+	return result.String() + marker
+}
@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"context"
+	"sync"
+
+	"github.com/anans9/ai-git/internal/config"
+	"github.com/anans9/ai-git/internal/tokens"
+)
+
+type usageRecorderKey struct{}
+
+// usageRecorder collects the token usage reported while a request runs.
+// Providers that make several calls for one request add up their usage.
+type usageRecorder struct {
+	mu        sync.Mutex
+	model     string
+	usage     Usage
+	estimated bool
+	reported  bool
+}
+
+// withUsageRecorder returns a context that collects token usage
+func withUsageRecorder(ctx context.Context) (context.Context, *usageRecorder) {
+	recorder := &usageRecorder{}
+	return context.WithValue(ctx, usageRecorderKey{}, recorder), recorder
+}
+
+// recordUsage adds usage reported by model to the recorder in ctx, if any
+func recordUsage(ctx context.Context, model string, usage Usage) {
+	recorder, ok := ctx.Value(usageRecorderKey{}).(*usageRecorder)
+	if !ok {
+		return
+	}
+
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	recorder.model = model
+	recorder.usage.PromptTokens += usage.PromptTokens
+	recorder.usage.CompletionTokens += usage.CompletionTokens
+	recorder.usage.TotalTokens += usage.TotalTokens
+	recorder.reported = true
+}
+
+// recordEstimatedUsage records usage estimated from the prompt and
+// completion text for providers that do not report it
+func recordEstimatedUsage(ctx context.Context, cfg *config.Config, model, prompt, completion string) {
+	recordUsage(ctx, model, Usage{
+		PromptTokens:     estimateTokens(cfg, model, prompt),
+		CompletionTokens: estimateTokens(cfg, model, completion),
+	})
+
+	if recorder, ok := ctx.Value(usageRecorderKey{}).(*usageRecorder); ok {
+		recorder.mu.Lock()
+		recorder.estimated = true
+		recorder.mu.Unlock()
+	}
+}
+
+// estimateTokens estimates the number of tokens in text using the
+// tokenizer family of model
+func estimateTokens(cfg *config.Config, model, text string) int {
+	tokenizer := ""
+	if info, ok := cfg.LookupModel(model); ok {
+		tokenizer = info.Tokenizer
+	}
+	return tokens.Estimate(text, tokenizer)
+}
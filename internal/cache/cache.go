@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// Cache stores AI responses on disk so identical requests can be answered
+// without calling the provider again
+type Cache struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+}
+
+// Entry represents a single cached response
+type Entry struct {
+	Key       string          `json:"key"`
+	CreatedAt time.Time       `json:"created_at"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// Stats describes the current contents of the cache
+type Stats struct {
+	Dir     string
+	Entries int
+	Expired int
+	Size    int64
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+// New creates a cache rooted at dir. Entries older than ttl are ignored and
+// the oldest entries are evicted once the total size exceeds maxSize bytes.
+// A zero ttl or maxSize disables that limit.
+func New(dir string, ttl time.Duration, maxSize int64) *Cache {
+	return &Cache{
+		dir:     dir,
+		ttl:     ttl,
+		maxSize: maxSize,
+	}
+}
+
+// NewFromConfig creates a cache in the default directory using the
+// configured TTL and size limit
+func NewFromConfig(cfg config.CacheConfig) (*Cache, error) {
+	ttl, err := cfg.TTLDuration()
+	if err != nil {
+		return nil, err
+	}
+	return New(DefaultDir(), ttl, int64(cfg.MaxSizeMB)*1024*1024), nil
+}
+
+// DefaultDir returns the directory used for cached responses
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "ai-git-cache", "responses")
+	}
+	return filepath.Join(home, ".cache", "ai-git", "responses")
+}
+
+// Key derives a cache key from the given parts
+func Key(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Dir returns the cache directory
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get loads the value stored under key into v. It reports false if the key
+// is missing, expired or unreadable.
+func (c *Cache) Get(key string, v interface{}) bool {
+	path := c.path(key)
+	entry, err := readEntry(path)
+	if err != nil {
+		return false
+	}
+
+	if c.expired(entry) {
+		os.Remove(path)
+		return false
+	}
+
+	return json.Unmarshal(entry.Value, v) == nil
+}
+
+// Set stores v under key and evicts old entries if needed
+func (c *Cache) Set(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache value: %w", err)
+	}
+
+	data, err := json.Marshal(Entry{
+		Key:       key,
+		CreatedAt: time.Now(),
+		Value:     value,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see partial entries
+	tmp, err := os.CreateTemp(c.dir, "entry-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	return c.evict()
+}
+
+// Stats returns information about the cached entries
+func (c *Cache) Stats() (Stats, error) {
+	stats := Stats{Dir: c.dir}
+
+	files, err := c.files()
+	if err != nil {
+		return stats, err
+	}
+
+	for _, file := range files {
+		stats.Entries++
+		stats.Size += file.size
+		if c.ttl > 0 && time.Since(file.modTime) > c.ttl {
+			stats.Expired++
+		}
+		if stats.Oldest.IsZero() || file.modTime.Before(stats.Oldest) {
+			stats.Oldest = file.modTime
+		}
+		if file.modTime.After(stats.Newest) {
+			stats.Newest = file.modTime
+		}
+	}
+
+	return stats, nil
+}
+
+// Clear removes all cached entries
+func (c *Cache) Clear() error {
+	if err := os.RemoveAll(c.dir); err != nil {
+		return fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return nil
+}
+
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *Cache) expired(entry *Entry) bool {
+	return c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl
+}
+
+func (c *Cache) files() ([]cacheFile, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	files := []cacheFile{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{
+			path:    filepath.Join(c.dir, entry.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	return files, nil
+}
+
+// evict removes expired entries, then the oldest entries until the cache
+// fits within its size limit
+func (c *Cache) evict() error {
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	live := []cacheFile{}
+	for _, file := range files {
+		if c.ttl > 0 && time.Since(file.modTime) > c.ttl {
+			os.Remove(file.path)
+			continue
+		}
+		total += file.size
+		live = append(live, file)
+	}
+
+	if c.maxSize <= 0 || total <= c.maxSize {
+		return nil
+	}
+
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].modTime.Before(live[j].modTime)
+	})
+
+	for _, file := range live {
+		if total <= c.maxSize {
+			break
+		}
+		if err := os.Remove(file.path); err == nil {
+			total -= file.size
+		}
+	}
+
+	return nil
+}
+
+func readEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
@@ -2,9 +2,13 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"time"
 
+	"github.com/anans9/ai-git/internal/prompt"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
@@ -25,15 +29,135 @@ type AIConfig struct {
 	Temperature  float64               `yaml:"temperature" mapstructure:"temperature"`
 	MaxTokens    int                   `yaml:"max_tokens" mapstructure:"max_tokens"`
 	SystemPrompt string                `yaml:"system_prompt" mapstructure:"system_prompt"`
+	Language     string                `yaml:"language,omitempty" mapstructure:"language"`
+	Stream       bool                  `yaml:"stream" mapstructure:"stream"`
+	Structured   bool                  `yaml:"structured" mapstructure:"structured"`
+	Cache        CacheConfig           `yaml:"cache" mapstructure:"cache"`
+	Retry        RetryConfig           `yaml:"retry" mapstructure:"retry"`
+	Timeouts     TimeoutConfig         `yaml:"timeouts" mapstructure:"timeouts"`
+	Fallback     []string              `yaml:"fallback,omitempty" mapstructure:"fallback"`
+	Usage        UsageConfig           `yaml:"usage" mapstructure:"usage"`
+	Summarize    SummarizeConfig       `yaml:"summarize" mapstructure:"summarize"`
+	Examples     ExamplesConfig        `yaml:"examples" mapstructure:"examples"`
+	Redact       RedactConfig          `yaml:"redact" mapstructure:"redact"`
+	Network      NetworkConfig         `yaml:"network" mapstructure:"network"`
+	Record       string                `yaml:"record,omitempty" mapstructure:"record"`
+	Models       []ModelInfo           `yaml:"models,omitempty" mapstructure:"models"`
 	Providers    map[string]AIProvider `yaml:"providers" mapstructure:"providers"`
 }
 
+// CacheConfig controls the on-disk cache of AI responses
+type CacheConfig struct {
+	Enabled   bool   `yaml:"enabled" mapstructure:"enabled"`
+	TTL       string `yaml:"ttl" mapstructure:"ttl"`
+	MaxSizeMB int    `yaml:"max_size_mb" mapstructure:"max_size_mb"`
+}
+
+// RetryConfig controls retries of failed AI provider requests
+type RetryConfig struct {
+	MaxAttempts  int    `yaml:"max_attempts" mapstructure:"max_attempts"`
+	InitialDelay string `yaml:"initial_delay" mapstructure:"initial_delay"`
+	MaxDelay     string `yaml:"max_delay" mapstructure:"max_delay"`
+}
+
+// TimeoutConfig limits how long each AI operation may take, including
+// retries and fallback providers. Durations use Go syntax such as 90s or 5m;
+// 0 means no limit.
+type TimeoutConfig struct {
+	Commit    string `yaml:"commit" mapstructure:"commit"`
+	Review    string `yaml:"review" mapstructure:"review"`
+	Summarize string `yaml:"summarize" mapstructure:"summarize"`
+}
+
+// UsageConfig controls the ledger of token usage
+type UsageConfig struct {
+	Enabled bool         `yaml:"enabled" mapstructure:"enabled"`
+	Prices  []ModelPrice `yaml:"prices,omitempty" mapstructure:"prices"`
+}
+
+// SummarizeConfig controls how diffs that do not fit in the token budget are
+// summarized in parts before generating a message
+type SummarizeConfig struct {
+	Enabled       bool `yaml:"enabled" mapstructure:"enabled"`
+	MaxTokens     int  `yaml:"max_tokens" mapstructure:"max_tokens"`
+	ChunkTokens   int  `yaml:"chunk_tokens" mapstructure:"chunk_tokens"`
+	SummaryTokens int  `yaml:"summary_tokens" mapstructure:"summary_tokens"`
+	Concurrency   int  `yaml:"concurrency" mapstructure:"concurrency"`
+}
+
+// ExamplesConfig controls the commits from the repository's history shown to
+// the model as examples of the project's commit style
+type ExamplesConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Count is the number of examples to include
+	Count int `yaml:"count" mapstructure:"count"`
+	// Scan is the number of recent commits to choose the examples from
+	Scan int `yaml:"scan" mapstructure:"scan"`
+}
+
+// RedactConfig controls the replacement of suspected secrets in prompts
+// before they are sent to a provider
+type RedactConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Entropy is the minimum Shannon entropy, in bits per character, of
+	// long random-looking strings treated as secrets. 0 turns the check off.
+	Entropy float64 `yaml:"entropy" mapstructure:"entropy"`
+	// Patterns are additional regular expressions for secrets
+	Patterns []RedactPattern `yaml:"patterns,omitempty" mapstructure:"patterns"`
+}
+
+// RedactPattern is a user-defined secret pattern. When the regular
+// expression has a capture group only the first group is redacted.
+type RedactPattern struct {
+	Name  string `yaml:"name" mapstructure:"name"`
+	Regex string `yaml:"regex" mapstructure:"regex"`
+}
+
+// NetworkConfig controls the HTTP transport shared by all providers
+type NetworkConfig struct {
+	// Proxy is the URL of the proxy for provider requests. Without it the
+	// HTTPS_PROXY and HTTP_PROXY environment variables apply.
+	Proxy string `yaml:"proxy,omitempty" mapstructure:"proxy"`
+	// NoProxy lists the hosts reached directly, in the syntax of NO_PROXY
+	NoProxy string `yaml:"no_proxy,omitempty" mapstructure:"no_proxy"`
+	// CACert is a PEM bundle of certificate authorities trusted in addition
+	// to the system ones, such as that of a TLS-intercepting proxy
+	CACert string `yaml:"ca_cert,omitempty" mapstructure:"ca_cert"`
+	// ClientCert and ClientKey are the PEM certificate and key presented to
+	// servers that require mutual TLS
+	ClientCert string `yaml:"client_cert,omitempty" mapstructure:"client_cert"`
+	ClientKey  string `yaml:"client_key,omitempty" mapstructure:"client_key"`
+	// InsecureSkipVerify turns off the verification of server certificates
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty" mapstructure:"insecure_skip_verify"`
+}
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	Model  string  `yaml:"model" mapstructure:"model"`
+	Input  float64 `yaml:"input" mapstructure:"input"`
+	Output float64 `yaml:"output" mapstructure:"output"`
+}
+
 // AIProvider represents configuration for a specific AI provider
 type AIProvider struct {
-	APIKey  string `yaml:"api_key,omitempty" mapstructure:"api_key"`
-	BaseURL string `yaml:"base_url,omitempty" mapstructure:"base_url"`
-	Model   string `yaml:"model" mapstructure:"model"`
-	Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
+	Type       string            `yaml:"type,omitempty" mapstructure:"type"`
+	APIKey     string            `yaml:"api_key,omitempty" mapstructure:"api_key"`
+	BaseURL    string            `yaml:"base_url,omitempty" mapstructure:"base_url"`
+	Model      string            `yaml:"model" mapstructure:"model"`
+	Enabled    bool              `yaml:"enabled" mapstructure:"enabled"`
+	Command    string            `yaml:"command,omitempty" mapstructure:"command"`
+	Args       []string          `yaml:"args,omitempty" mapstructure:"args"`
+	Headers    map[string]string `yaml:"headers,omitempty" mapstructure:"headers"`
+	Endpoint   string            `yaml:"endpoint,omitempty" mapstructure:"endpoint"`
+	Deployment string            `yaml:"deployment,omitempty" mapstructure:"deployment"`
+	APIVersion string            `yaml:"api_version,omitempty" mapstructure:"api_version"`
+	AuthHeader string            `yaml:"auth_header,omitempty" mapstructure:"auth_header"`
+	AuthScheme string            `yaml:"auth_scheme,omitempty" mapstructure:"auth_scheme"`
+	// Cassette is the file of recorded responses served by a replay provider
+	Cassette string `yaml:"cassette,omitempty" mapstructure:"cassette"`
+	// Timeout limits each request to the provider. Empty keeps the
+	// provider's default and 0 means no limit.
+	Timeout string `yaml:"timeout,omitempty" mapstructure:"timeout"`
 }
 
 // GitConfig holds Git-related configuration
@@ -114,6 +238,42 @@ var defaultConfig = Config{
 		SystemPrompt: `You are an expert software engineer helping to write commit messages.
 Generate concise, descriptive commit messages that follow conventional commit format.
 Focus on what changed and why. Be specific but brief.`,
+		Stream:     true,
+		Structured: true,
+		Cache: CacheConfig{
+			Enabled:   true,
+			TTL:       "24h",
+			MaxSizeMB: 50,
+		},
+		Retry: RetryConfig{
+			MaxAttempts:  3,
+			InitialDelay: "1s",
+			MaxDelay:     "30s",
+		},
+		Timeouts: TimeoutConfig{
+			Commit:    "30s",
+			Review:    "2m",
+			Summarize: "2m",
+		},
+		Usage: UsageConfig{
+			Enabled: true,
+		},
+		Summarize: SummarizeConfig{
+			Enabled:       true,
+			MaxTokens:     6000,
+			ChunkTokens:   6000,
+			SummaryTokens: 300,
+			Concurrency:   4,
+		},
+		Examples: ExamplesConfig{
+			Enabled: false,
+			Count:   3,
+			Scan:    200,
+		},
+		Redact: RedactConfig{
+			Enabled: true,
+			Entropy: 4.5,
+		},
 		Providers: map[string]AIProvider{
 			"openai": {
 				Model:   "gpt-4",
@@ -123,11 +283,18 @@ Focus on what changed and why. Be specific but brief.`,
 				Model:   "claude-3-sonnet-20240229",
 				Enabled: false,
 			},
+			"gemini": {
+				Model:   "gemini-1.5-flash",
+				Enabled: false,
+			},
 			"local": {
 				BaseURL: "http://localhost:11434",
 				Model:   "codellama",
 				Enabled: false,
 			},
+			"heuristic": {
+				Enabled: false,
+			},
 		},
 	},
 	Git: GitConfig{
@@ -158,6 +325,12 @@ Focus on what changed and why. Be specific but brief.`,
 		Prompts: PromptConfig{
 			CommitMessage: `Analyze the following git diff and generate a concise commit message.
 Follow conventional commit format: type(scope): description
+{{if .Types}}
+Use one of these types: {{join .Types ", "}}
+{{- end}}
+{{- if .Scopes}}
+Common scopes in this project: {{join .Scopes ", "}}
+{{- end}}
 
 Rules:
 - Use present tense ("add" not "added")
@@ -165,9 +338,26 @@ Rules:
 - No period at the end
 - Maximum 50 characters for the first line
 - Focus on what and why, not how
+{{if .Branch}}
+Branch: {{.Branch}}{{if .Ticket}} (ticket {{.Ticket}}){{end}}
+{{- end}}
+{{- if .Examples}}
+
+Examples of commit messages in this repository; match their style, scopes and casing:
+{{- range .Examples}}
+---
+{{.}}
+{{- end}}
+---
+{{- else if .RecentCommits}}
+Recent commits, for style:
+{{- range .RecentCommits}}
+- {{.}}
+{{- end}}
+{{- end}}
 
 Git diff:
-{diff}
+{{.Diff}}
 
 Commit message:`,
 			PRTitle: `Generate a clear and descriptive pull request title based on the changes:
@@ -186,6 +376,17 @@ Include:
 - Testing information
 
 Description:`,
+			CodeReview: `Review the following git diff as an experienced code reviewer.
+Look for bugs, security issues, performance problems and unclear code.
+
+Rules:
+- Only report real problems in the added or changed lines
+- Give the file and the line number in the new version of the file
+- Rate each problem as low, medium or high severity
+- Keep each message short and say how to fix the problem
+
+Git diff:
+{{.Diff}}`,
 		},
 		Patterns: CommitPatterns{
 			Conventional: true,
@@ -254,6 +455,36 @@ func SetDefaults() {
 	viper.SetDefault("ai.temperature", defaultConfig.AI.Temperature)
 	viper.SetDefault("ai.max_tokens", defaultConfig.AI.MaxTokens)
 	viper.SetDefault("ai.system_prompt", defaultConfig.AI.SystemPrompt)
+	viper.SetDefault("ai.language", defaultConfig.AI.Language)
+	viper.SetDefault("ai.stream", defaultConfig.AI.Stream)
+	viper.SetDefault("ai.structured", defaultConfig.AI.Structured)
+	viper.SetDefault("ai.cache.enabled", defaultConfig.AI.Cache.Enabled)
+	viper.SetDefault("ai.cache.ttl", defaultConfig.AI.Cache.TTL)
+	viper.SetDefault("ai.cache.max_size_mb", defaultConfig.AI.Cache.MaxSizeMB)
+	viper.SetDefault("ai.retry.max_attempts", defaultConfig.AI.Retry.MaxAttempts)
+	viper.SetDefault("ai.retry.initial_delay", defaultConfig.AI.Retry.InitialDelay)
+	viper.SetDefault("ai.retry.max_delay", defaultConfig.AI.Retry.MaxDelay)
+	viper.SetDefault("ai.timeouts.commit", defaultConfig.AI.Timeouts.Commit)
+	viper.SetDefault("ai.timeouts.review", defaultConfig.AI.Timeouts.Review)
+	viper.SetDefault("ai.timeouts.summarize", defaultConfig.AI.Timeouts.Summarize)
+	viper.SetDefault("ai.fallback", defaultConfig.AI.Fallback)
+	viper.SetDefault("ai.usage.enabled", defaultConfig.AI.Usage.Enabled)
+	viper.SetDefault("ai.summarize.enabled", defaultConfig.AI.Summarize.Enabled)
+	viper.SetDefault("ai.summarize.max_tokens", defaultConfig.AI.Summarize.MaxTokens)
+	viper.SetDefault("ai.summarize.chunk_tokens", defaultConfig.AI.Summarize.ChunkTokens)
+	viper.SetDefault("ai.summarize.summary_tokens", defaultConfig.AI.Summarize.SummaryTokens)
+	viper.SetDefault("ai.summarize.concurrency", defaultConfig.AI.Summarize.Concurrency)
+	viper.SetDefault("ai.examples.enabled", defaultConfig.AI.Examples.Enabled)
+	viper.SetDefault("ai.examples.count", defaultConfig.AI.Examples.Count)
+	viper.SetDefault("ai.examples.scan", defaultConfig.AI.Examples.Scan)
+	viper.SetDefault("ai.redact.enabled", defaultConfig.AI.Redact.Enabled)
+	viper.SetDefault("ai.redact.entropy", defaultConfig.AI.Redact.Entropy)
+	viper.SetDefault("ai.network.proxy", defaultConfig.AI.Network.Proxy)
+	viper.SetDefault("ai.network.no_proxy", defaultConfig.AI.Network.NoProxy)
+	viper.SetDefault("ai.network.ca_cert", defaultConfig.AI.Network.CACert)
+	viper.SetDefault("ai.network.client_cert", defaultConfig.AI.Network.ClientCert)
+	viper.SetDefault("ai.network.client_key", defaultConfig.AI.Network.ClientKey)
+	viper.SetDefault("ai.network.insecure_skip_verify", defaultConfig.AI.Network.InsecureSkipVerify)
 
 	// Git defaults
 	viper.SetDefault("git.auto_stage", defaultConfig.Git.AutoStage)
@@ -274,6 +505,10 @@ func SetDefaults() {
 	viper.SetDefault("templates.patterns.conventional", defaultConfig.Templates.Patterns.Conventional)
 	viper.SetDefault("templates.patterns.types", defaultConfig.Templates.Patterns.Types)
 	viper.SetDefault("templates.patterns.scopes", defaultConfig.Templates.Patterns.Scopes)
+	viper.SetDefault("templates.prompts.commit_message", defaultConfig.Templates.Prompts.CommitMessage)
+	viper.SetDefault("templates.prompts.pr_title", defaultConfig.Templates.Prompts.PRTitle)
+	viper.SetDefault("templates.prompts.pr_description", defaultConfig.Templates.Prompts.PRDescription)
+	viper.SetDefault("templates.prompts.code_review", defaultConfig.Templates.Prompts.CodeReview)
 }
 
 // Load loads the configuration from viper
@@ -360,10 +595,202 @@ func (c *Config) Validate() error {
 	if c.AI.MaxTokens <= 0 {
 		return fmt.Errorf("max_tokens must be positive")
 	}
+	if err := c.validateModels(); err != nil {
+		return err
+	}
+
+	// Validate cache settings
+	if _, err := c.AI.Cache.TTLDuration(); err != nil {
+		return err
+	}
+	if c.AI.Cache.MaxSizeMB < 0 {
+		return fmt.Errorf("cache max_size_mb must not be negative")
+	}
+
+	// Validate retry settings
+	if c.AI.Retry.MaxAttempts < 0 {
+		return fmt.Errorf("retry max_attempts must not be negative")
+	}
+	if _, _, err := c.AI.Retry.Delays(); err != nil {
+		return err
+	}
+
+	// Validate timeouts
+	for _, operation := range []string{"commit", "review", "summarize"} {
+		if _, err := c.AI.Timeouts.Timeout(operation); err != nil {
+			return err
+		}
+	}
+	for name, provider := range c.AI.Providers {
+		if _, err := provider.RequestTimeout(0); err != nil {
+			return fmt.Errorf("provider %s: %w", name, err)
+		}
+	}
+
+	// Validate fallback providers
+	for _, name := range c.AI.Fallback {
+		if _, exists := c.AI.Providers[name]; !exists {
+			return fmt.Errorf("unknown fallback provider: %s", name)
+		}
+	}
+
+	// Validate usage prices
+	for _, price := range c.AI.Usage.Prices {
+		if price.Model == "" {
+			return fmt.Errorf("usage price is missing a model")
+		}
+		if price.Input < 0 || price.Output < 0 {
+			return fmt.Errorf("usage price for %s must not be negative", price.Model)
+		}
+	}
+
+	// Validate summarization settings
+	if c.AI.Summarize.Enabled {
+		if c.AI.Summarize.MaxTokens <= 0 || c.AI.Summarize.ChunkTokens <= 0 || c.AI.Summarize.SummaryTokens <= 0 {
+			return fmt.Errorf("summarize max_tokens, chunk_tokens and summary_tokens must be positive")
+		}
+		if c.AI.Summarize.Concurrency < 1 {
+			return fmt.Errorf("summarize concurrency must be at least 1")
+		}
+	}
+
+	// Validate commit examples
+	if c.AI.Examples.Enabled {
+		if c.AI.Examples.Count < 1 {
+			return fmt.Errorf("examples count must be at least 1")
+		}
+		if c.AI.Examples.Scan < c.AI.Examples.Count {
+			return fmt.Errorf("examples scan must be at least the examples count")
+		}
+	}
+
+	// Validate redaction settings
+	if c.AI.Redact.Entropy < 0 || c.AI.Redact.Entropy > 8 {
+		return fmt.Errorf("redact entropy must be between 0 and 8 bits per character")
+	}
+	for _, pattern := range c.AI.Redact.Patterns {
+		if pattern.Regex == "" {
+			return fmt.Errorf("redact pattern %q is missing a regex", pattern.Name)
+		}
+		if _, err := regexp.Compile(pattern.Regex); err != nil {
+			return fmt.Errorf("invalid redact pattern %q: %w", pattern.Name, err)
+		}
+	}
+
+	// Validate network settings
+	if c.AI.Network.Proxy != "" {
+		proxy, err := url.Parse(c.AI.Network.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid network proxy: %w", err)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("network proxy must be an http, https or socks5 URL")
+		}
+		if proxy.Host == "" {
+			return fmt.Errorf("network proxy %s is missing a host", c.AI.Network.Proxy)
+		}
+	}
+	if (c.AI.Network.ClientCert == "") != (c.AI.Network.ClientKey == "") {
+		return fmt.Errorf("network client_cert and client_key must be set together")
+	}
+
+	// Validate prompt templates
+	prompts := []struct{ name, text string }{
+		{"commit_message", c.Templates.Prompts.CommitMessage},
+		{"pr_title", c.Templates.Prompts.PRTitle},
+		{"pr_description", c.Templates.Prompts.PRDescription},
+		{"code_review", c.Templates.Prompts.CodeReview},
+	}
+	for _, p := range prompts {
+		if err := prompt.Check(p.text); err != nil {
+			return fmt.Errorf("invalid %s prompt: %w", p.name, err)
+		}
+	}
 
 	return nil
 }
 
+// TTLDuration parses the cache TTL. An empty TTL means entries never expire.
+func (c CacheConfig) TTLDuration() (time.Duration, error) {
+	if c.TTL == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(c.TTL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache ttl %q: %w", c.TTL, err)
+	}
+	if ttl < 0 {
+		return 0, fmt.Errorf("cache ttl must not be negative")
+	}
+	return ttl, nil
+}
+
+// Delays parses the initial and maximum retry delays
+func (r RetryConfig) Delays() (time.Duration, time.Duration, error) {
+	var initialDelay, maxDelay time.Duration
+	var err error
+
+	if r.InitialDelay != "" {
+		if initialDelay, err = time.ParseDuration(r.InitialDelay); err != nil {
+			return 0, 0, fmt.Errorf("invalid retry initial_delay %q: %w", r.InitialDelay, err)
+		}
+	}
+	if r.MaxDelay != "" {
+		if maxDelay, err = time.ParseDuration(r.MaxDelay); err != nil {
+			return 0, 0, fmt.Errorf("invalid retry max_delay %q: %w", r.MaxDelay, err)
+		}
+	}
+
+	if initialDelay < 0 || maxDelay < 0 {
+		return 0, 0, fmt.Errorf("retry delays must not be negative")
+	}
+
+	return initialDelay, maxDelay, nil
+}
+
+// Timeout returns the time limit of an AI operation: commit, review or
+// summarize. 0 means no limit.
+func (t TimeoutConfig) Timeout(operation string) (time.Duration, error) {
+	timeouts := map[string]string{
+		"commit":    t.Commit,
+		"review":    t.Review,
+		"summarize": t.Summarize,
+	}
+
+	value, exists := timeouts[operation]
+	if !exists {
+		return 0, fmt.Errorf("unknown operation: %s", operation)
+	}
+	return parseTimeout(operation, value)
+}
+
+// RequestTimeout returns the limit on each request to the provider, or def
+// when none is configured. 0 means no limit.
+func (p AIProvider) RequestTimeout(def time.Duration) (time.Duration, error) {
+	if p.Timeout == "" {
+		return def, nil
+	}
+	return parseTimeout("request", p.Timeout)
+}
+
+// parseTimeout parses a timeout. An empty timeout means no limit.
+func parseTimeout(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s timeout %q: %w", name, value, err)
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("%s timeout must not be negative", name)
+	}
+	return timeout, nil
+}
+
 // GetProvider returns the configuration for the specified provider
 func (c *Config) GetProvider(name string) (AIProvider, error) {
 	provider, exists := c.AI.Providers[name]
@@ -373,6 +800,27 @@ func (c *Config) GetProvider(name string) (AIProvider, error) {
 	return provider, nil
 }
 
+// ModelFor returns the model to request from the named provider. The
+// default provider uses ai.model; other providers use their own model.
+func (c *Config) ModelFor(name string) string {
+	if name == c.AI.Provider {
+		return c.AI.Model
+	}
+	if provider, exists := c.AI.Providers[name]; exists && provider.Model != "" {
+		return provider.Model
+	}
+	return c.AI.Model
+}
+
+// ProviderType returns the type of the provider configured under name,
+// which defaults to the name itself
+func (p AIProvider) ProviderType(name string) string {
+	if p.Type != "" {
+		return p.Type
+	}
+	return name
+}
+
 // SetProvider updates the configuration for a provider
 func (c *Config) SetProvider(name string, provider AIProvider) {
 	if c.AI.Providers == nil {
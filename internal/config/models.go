@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anans9/ai-git/internal/tokens"
+)
+
+// ModelInfo describes the limits of a model
+type ModelInfo struct {
+	Name          string `yaml:"name" mapstructure:"name"`
+	ContextWindow int    `yaml:"context_window" mapstructure:"context_window"`
+	MaxOutput     int    `yaml:"max_output" mapstructure:"max_output"`
+	Tokenizer     string `yaml:"tokenizer" mapstructure:"tokenizer"`
+	// JSONOutput reports whether the model can be asked for a JSON object
+	// through JSON mode, a response MIME type or a forced tool call
+	JSONOutput bool `yaml:"json_output,omitempty" mapstructure:"json_output"`
+}
+
+// defaultModels lists the limits of well-known models
+var defaultModels = []ModelInfo{
+	{Name: "gpt-4", ContextWindow: 8192, MaxOutput: 4096, Tokenizer: tokens.CL100K},
+	{Name: "gpt-4-32k", ContextWindow: 32768, MaxOutput: 4096, Tokenizer: tokens.CL100K},
+	{Name: "gpt-4-turbo", ContextWindow: 128000, MaxOutput: 4096, Tokenizer: tokens.CL100K, JSONOutput: true},
+	{Name: "gpt-4o", ContextWindow: 128000, MaxOutput: 16384, Tokenizer: tokens.O200K, JSONOutput: true},
+	{Name: "gpt-4o-mini", ContextWindow: 128000, MaxOutput: 16384, Tokenizer: tokens.O200K, JSONOutput: true},
+	{Name: "gpt-3.5-turbo", ContextWindow: 16385, MaxOutput: 4096, Tokenizer: tokens.CL100K, JSONOutput: true},
+	{Name: "claude-3-opus", ContextWindow: 200000, MaxOutput: 4096, Tokenizer: tokens.Claude, JSONOutput: true},
+	{Name: "claude-3-sonnet", ContextWindow: 200000, MaxOutput: 4096, Tokenizer: tokens.Claude, JSONOutput: true},
+	{Name: "claude-3-haiku", ContextWindow: 200000, MaxOutput: 4096, Tokenizer: tokens.Claude, JSONOutput: true},
+	{Name: "claude-3-5-sonnet", ContextWindow: 200000, MaxOutput: 8192, Tokenizer: tokens.Claude, JSONOutput: true},
+	{Name: "claude-3-5-haiku", ContextWindow: 200000, MaxOutput: 8192, Tokenizer: tokens.Claude, JSONOutput: true},
+	{Name: "gemini-1.5-flash", ContextWindow: 1048576, MaxOutput: 8192, Tokenizer: tokens.Gemini, JSONOutput: true},
+	{Name: "gemini-1.5-pro", ContextWindow: 2097152, MaxOutput: 8192, Tokenizer: tokens.Gemini, JSONOutput: true},
+	{Name: "codellama", ContextWindow: 16384, MaxOutput: 4096, Tokenizer: tokens.Llama},
+	{Name: "llama3", ContextWindow: 8192, MaxOutput: 4096, Tokenizer: tokens.Llama},
+	{Name: "mistral", ContextWindow: 32768, MaxOutput: 4096, Tokenizer: tokens.Llama},
+}
+
+// LookupModel returns the limits of model. Entries in ai.models override the
+// built-in ones field by field. Versioned names such as
+// claude-3-sonnet-20240229 or codellama:13b use the longest matching prefix.
+func (c *Config) LookupModel(model string) (ModelInfo, bool) {
+	var best ModelInfo
+	found := false
+
+	for _, info := range c.models() {
+		if info.Name == model {
+			return info, true
+		}
+		if (strings.HasPrefix(model, info.Name+"-") || strings.HasPrefix(model, info.Name+":")) &&
+			len(info.Name) > len(best.Name) {
+			best = info
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+// models merges the configured models into the built-in catalog
+func (c *Config) models() []ModelInfo {
+	models := append([]ModelInfo{}, defaultModels...)
+
+	for _, override := range c.AI.Models {
+		merged := false
+		for i := range models {
+			if models[i].Name != override.Name {
+				continue
+			}
+			if override.ContextWindow > 0 {
+				models[i].ContextWindow = override.ContextWindow
+			}
+			if override.MaxOutput > 0 {
+				models[i].MaxOutput = override.MaxOutput
+			}
+			if override.Tokenizer != "" {
+				models[i].Tokenizer = override.Tokenizer
+			}
+			if override.JSONOutput {
+				models[i].JSONOutput = true
+			}
+			merged = true
+		}
+		if !merged {
+			models = append(models, override)
+		}
+	}
+
+	return models
+}
+
+// validateModels checks the configured model catalog and that max_tokens
+// fits the limits of the primary provider's model
+func (c *Config) validateModels() error {
+	for _, info := range c.AI.Models {
+		if info.Name == "" {
+			return fmt.Errorf("model entry is missing a name")
+		}
+		if info.ContextWindow < 0 || info.MaxOutput < 0 {
+			return fmt.Errorf("limits of model %s must not be negative", info.Name)
+		}
+		if info.Tokenizer != "" && !tokens.Known(info.Tokenizer) {
+			return fmt.Errorf("unknown tokenizer %s for model %s (expected one of: %s)",
+				info.Tokenizer, info.Name, strings.Join(tokens.Families(), ", "))
+		}
+	}
+
+	// Some provider types prefer their own model over ai.model, so check both
+	models := []string{c.ModelFor(c.AI.Provider)}
+	if provider, exists := c.AI.Providers[c.AI.Provider]; exists && provider.Model != "" && provider.Model != models[0] {
+		models = append(models, provider.Model)
+	}
+
+	for _, model := range models {
+		info, ok := c.LookupModel(model)
+		if !ok {
+			continue
+		}
+		if info.MaxOutput > 0 && c.AI.MaxTokens > info.MaxOutput {
+			return fmt.Errorf("max_tokens %d exceeds the %d output tokens supported by %s", c.AI.MaxTokens, info.MaxOutput, model)
+		}
+		if info.ContextWindow > 0 && c.AI.MaxTokens >= info.ContextWindow {
+			return fmt.Errorf("max_tokens %d leaves no room for the prompt in the %d-token context of %s", c.AI.MaxTokens, info.ContextWindow, model)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,490 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/binary"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// diffContextLines is the number of unchanged lines shown around each hunk,
+// matching the default of `git diff`
+const diffContextLines = 3
+
+// diffFile is one side of a file comparison (HEAD tree, index or work tree)
+type diffFile struct {
+	path    string
+	hash    plumbing.Hash
+	mode    filemode.FileMode
+	content []byte
+}
+
+func (f *diffFile) Hash() plumbing.Hash     { return f.hash }
+func (f *diffFile) Mode() filemode.FileMode { return f.mode }
+func (f *diffFile) Path() string            { return f.path }
+
+// filePatch implements diff.FilePatch so the go-git unified encoder can
+// render hunks for sides that are not both backed by tree objects
+type filePatch struct {
+	from   *diffFile
+	to     *diffFile
+	binary bool
+	chunks []fdiff.Chunk
+}
+
+func (p *filePatch) IsBinary() bool        { return p.binary }
+func (p *filePatch) Chunks() []fdiff.Chunk { return p.chunks }
+
+func (p *filePatch) Files() (fdiff.File, fdiff.File) {
+	// Return untyped nils so the encoder can detect added and deleted files
+	var from, to fdiff.File
+	if p.from != nil {
+		from = p.from
+	}
+	if p.to != nil {
+		to = p.to
+	}
+	return from, to
+}
+
+type chunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c chunk) Content() string       { return c.content }
+func (c chunk) Type() fdiff.Operation { return c.op }
+
+type patch []fdiff.FilePatch
+
+func (p patch) FilePatches() []fdiff.FilePatch { return p }
+func (p patch) Message() string                { return "" }
+
+func (c *Client) getDiff(staged bool) (*Diff, error) {
+	status, err := c.workTree.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	idx, err := c.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var headTree *object.Tree
+	if staged {
+		headTree, err = c.headTree()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make([]string, 0, len(status))
+	for filePath, fileStatus := range status {
+		if staged {
+			if fileStatus.Staging == git.Unmodified || fileStatus.Staging == git.Untracked {
+				continue
+			}
+		} else if fileStatus.Worktree == git.Unmodified {
+			continue
+		}
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	var patches []*filePatch
+	for _, filePath := range paths {
+		var from, to *diffFile
+		if staged {
+			if from, err = c.treeFile(headTree, filePath); err != nil {
+				return nil, err
+			}
+			if to, err = c.indexFile(idx, filePath); err != nil {
+				return nil, err
+			}
+		} else {
+			if from, err = c.indexFile(idx, filePath); err != nil {
+				return nil, err
+			}
+			if to, err = c.workTreeFile(filePath); err != nil {
+				return nil, err
+			}
+		}
+
+		if from == nil && to == nil {
+			continue
+		}
+		if from != nil && to != nil && from.hash == to.hash && from.mode == to.mode {
+			continue
+		}
+		patches = append(patches, &filePatch{from: from, to: to})
+	}
+
+	return buildDiff(detectRenames(patches))
+}
+
+// buildDiff renders file patches and totals their statistics
+func buildDiff(patches []*filePatch) (*Diff, error) {
+	result := &Diff{
+		Files: []FileDiff{},
+		Stats: DiffStats{},
+	}
+
+	for _, p := range patches {
+		fileDiff, err := buildFileDiff(p)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Files = append(result.Files, fileDiff)
+		result.Stats.Files++
+		result.Stats.Additions += fileDiff.Additions
+		result.Stats.Deletions += fileDiff.Deletions
+	}
+
+	return result, nil
+}
+
+// GetRangeDiff returns the diff between two commits. The range may be
+// "from..to", "from...to" to compare against the merge base of both, or a
+// single revision, which is compared with HEAD.
+func (c *Client) GetRangeDiff(revRange string) (*Diff, error) {
+	fromRev, toRev, mergeBase := revRange, "HEAD", false
+	if from, to, found := strings.Cut(revRange, "..."); found {
+		fromRev, toRev, mergeBase = from, to, true
+	} else if from, to, found := strings.Cut(revRange, ".."); found {
+		fromRev, toRev = from, to
+	}
+	if fromRev == "" {
+		fromRev = "HEAD"
+	}
+	if toRev == "" {
+		toRev = "HEAD"
+	}
+
+	fromCommit, err := c.resolveCommit(fromRev)
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := c.resolveCommit(toRev)
+	if err != nil {
+		return nil, err
+	}
+
+	if mergeBase {
+		bases, err := fromCommit.MergeBase(toCommit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find merge base of %s and %s: %w", fromRev, toRev, err)
+		}
+		if len(bases) == 0 {
+			return nil, fmt.Errorf("%s and %s have no common ancestor", fromRev, toRev)
+		}
+		fromCommit = bases[0]
+	}
+
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree of %s: %w", fromRev, err)
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree of %s: %w", toRev, err)
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s: %w", revRange, err)
+	}
+
+	var patches []*filePatch
+	for _, change := range changes {
+		var from, to *diffFile
+		if change.From.Name != "" {
+			if from, err = c.treeFile(fromTree, change.From.Name); err != nil {
+				return nil, err
+			}
+		}
+		if change.To.Name != "" {
+			if to, err = c.treeFile(toTree, change.To.Name); err != nil {
+				return nil, err
+			}
+		}
+		if from == nil && to == nil {
+			continue
+		}
+		patches = append(patches, &filePatch{from: from, to: to})
+	}
+	sort.Slice(patches, func(i, j int) bool {
+		return patchPath(patches[i]) < patchPath(patches[j])
+	})
+
+	return buildDiff(detectRenames(patches))
+}
+
+func (c *Client) resolveCommit(rev string) (*object.Commit, error) {
+	hash, err := c.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", rev, err)
+	}
+
+	commit, err := c.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit %s: %w", rev, err)
+	}
+
+	return commit, nil
+}
+
+func patchPath(p *filePatch) string {
+	if p.to != nil {
+		return p.to.path
+	}
+	return p.from.path
+}
+
+// headTree returns the tree of the HEAD commit, or nil on an unborn branch
+func (c *Client) headTree() (*object.Tree, error) {
+	head, err := c.repo.Head()
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+
+	headCommit, err := c.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+	}
+
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD tree: %w", err)
+	}
+
+	return headTree, nil
+}
+
+func (c *Client) treeFile(tree *object.Tree, filePath string) (*diffFile, error) {
+	if tree == nil {
+		return nil, nil
+	}
+
+	entry, err := tree.FindEntry(filePath)
+	if err != nil {
+		if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find %s in HEAD: %w", filePath, err)
+	}
+
+	return c.blobFile(filePath, entry.Hash, entry.Mode)
+}
+
+func (c *Client) indexFile(idx *index.Index, filePath string) (*diffFile, error) {
+	entry, err := idx.Entry(filePath)
+	if err != nil {
+		if errors.Is(err, index.ErrEntryNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find %s in index: %w", filePath, err)
+	}
+
+	return c.blobFile(filePath, entry.Hash, entry.Mode)
+}
+
+func (c *Client) blobFile(filePath string, hash plumbing.Hash, mode filemode.FileMode) (*diffFile, error) {
+	blob, err := c.repo.BlobObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob for %s: %w", filePath, err)
+	}
+
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob for %s: %w", filePath, err)
+	}
+	defer reader.Close()
+
+	var content bytes.Buffer
+	if _, err := content.ReadFrom(reader); err != nil {
+		return nil, fmt.Errorf("failed to read blob for %s: %w", filePath, err)
+	}
+
+	return &diffFile{
+		path:    filePath,
+		hash:    hash,
+		mode:    mode,
+		content: content.Bytes(),
+	}, nil
+}
+
+// workTreeFile reads filePath from the working tree. A path that is gone,
+// was replaced by a directory or cannot be read is treated as deleted, the
+// way git lists a tracked file replaced by a directory.
+func (c *Client) workTreeFile(filePath string) (*diffFile, error) {
+	fullPath := filepath.Join(c.repoPath, filePath)
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) || os.IsPermission(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to stat %s: %w", filePath, err)
+	}
+	if info.IsDir() {
+		return nil, nil
+	}
+
+	mode, err := filemode.NewFromOSFileMode(info.Mode())
+	if err != nil {
+		return nil, fmt.Errorf("unsupported file mode for %s: %w", filePath, err)
+	}
+
+	var content []byte
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read link %s: %w", filePath, err)
+		}
+		content = []byte(target)
+	} else {
+		content, err = os.ReadFile(fullPath)
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+		}
+	}
+
+	return &diffFile{
+		path:    filePath,
+		hash:    plumbing.ComputeHash(plumbing.BlobObject, content),
+		mode:    mode,
+		content: content,
+	}, nil
+}
+
+// detectRenames pairs deleted and added files with identical content into a
+// single rename patch. Only exact renames are found: a file that was renamed
+// and edited is shown as a deletion and an addition, unlike `git diff -M`
+// which also pairs similar files.
+func detectRenames(patches []*filePatch) []*filePatch {
+	added := map[plumbing.Hash][]int{}
+	for i, p := range patches {
+		if p.from == nil {
+			added[p.to.hash] = append(added[p.to.hash], i)
+		}
+	}
+
+	consumed := map[int]bool{}
+	for _, p := range patches {
+		if p.to != nil {
+			continue
+		}
+		candidates := added[p.from.hash]
+		if len(candidates) == 0 {
+			continue
+		}
+		target := candidates[0]
+		added[p.from.hash] = candidates[1:]
+		consumed[target] = true
+		p.to = patches[target].to
+	}
+
+	result := make([]*filePatch, 0, len(patches)-len(consumed))
+	for i, p := range patches {
+		if !consumed[i] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// buildFileDiff computes the hunks for a file patch and renders them in
+// unified format with context lines
+func buildFileDiff(p *filePatch) (FileDiff, error) {
+	fileDiff := FileDiff{}
+
+	switch {
+	case p.from == nil:
+		fileDiff.Path = p.to.path
+		fileDiff.Status = "A"
+	case p.to == nil:
+		fileDiff.Path = p.from.path
+		fileDiff.Status = "D"
+	case p.from.path != p.to.path:
+		fileDiff.Path = p.to.path
+		fileDiff.OldPath = p.from.path
+		fileDiff.Status = "R"
+	default:
+		fileDiff.Path = p.to.path
+		fileDiff.Status = "M"
+	}
+
+	var fromContent, toContent []byte
+	if p.from != nil {
+		fromContent = p.from.content
+	}
+	if p.to != nil {
+		toContent = p.to.content
+	}
+
+	for _, content := range [][]byte{fromContent, toContent} {
+		isBinary, err := binary.IsBinary(bytes.NewReader(content))
+		if err != nil {
+			return fileDiff, fmt.Errorf("failed to inspect %s: %w", fileDiff.Path, err)
+		}
+		p.binary = p.binary || isBinary
+	}
+
+	if !p.binary {
+		for _, d := range diff.Do(string(fromContent), string(toContent)) {
+			var op fdiff.Operation
+			switch d.Type {
+			case diffmatchpatch.DiffEqual:
+				op = fdiff.Equal
+			case diffmatchpatch.DiffInsert:
+				op = fdiff.Add
+				fileDiff.Additions += countLines(d.Text)
+			case diffmatchpatch.DiffDelete:
+				op = fdiff.Delete
+				fileDiff.Deletions += countLines(d.Text)
+			}
+			p.chunks = append(p.chunks, chunk{content: d.Text, op: op})
+		}
+	}
+
+	var content strings.Builder
+	encoder := fdiff.NewUnifiedEncoder(&content, diffContextLines)
+	if err := encoder.Encode(patch{p}); err != nil {
+		return fileDiff, fmt.Errorf("failed to encode diff for %s: %w", fileDiff.Path, err)
+	}
+	fileDiff.Content = strings.TrimSuffix(content.String(), "\n")
+
+	return fileDiff, nil
+}
+
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	n := strings.Count(s, "\n")
+	if !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
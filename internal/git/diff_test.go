@@ -0,0 +1,200 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testRepo is a temporary repository with helpers to change its files
+type testRepo struct {
+	t        *testing.T
+	dir      string
+	workTree *git.Worktree
+	client   *Client
+}
+
+// newTestRepo creates a repository whose first commit holds files
+func newTestRepo(t *testing.T, files map[string]string) *testRepo {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to init repository: %v", err)
+	}
+	workTree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get work tree: %v", err)
+	}
+
+	r := &testRepo{t: t, dir: dir, workTree: workTree}
+	for path, content := range files {
+		r.write(path, content)
+		r.add(path)
+	}
+	_, err = workTree.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(0, 0)},
+	})
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	r.client, err = NewClient(dir)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return r
+}
+
+func (r *testRepo) write(path, content string) {
+	r.t.Helper()
+	full := filepath.Join(r.dir, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *testRepo) remove(path string) {
+	r.t.Helper()
+	if err := os.RemoveAll(filepath.Join(r.dir, path)); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *testRepo) add(path string) {
+	r.t.Helper()
+	if _, err := r.workTree.Add(path); err != nil {
+		r.t.Fatalf("failed to stage %s: %v", path, err)
+	}
+}
+
+// files returns the path, old path and status of each file in diff
+func files(diff *Diff) []string {
+	var result []string
+	for _, file := range diff.Files {
+		entry := file.Status + " " + file.Path
+		if file.OldPath != "" {
+			entry += " <- " + file.OldPath
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+func assertFiles(t *testing.T, diff *Diff, want ...string) {
+	t.Helper()
+	if got := strings.Join(files(diff), ", "); got != strings.Join(want, ", ") {
+		t.Errorf("files = %q, want %q", got, strings.Join(want, ", "))
+	}
+}
+
+func TestStagedDiff(t *testing.T) {
+	r := newTestRepo(t, map[string]string{"a.txt": "one\ntwo\nthree\n", "b.txt": "gone\n"})
+
+	r.write("a.txt", "one\n2\nthree\n")
+	r.add("a.txt")
+	r.remove("b.txt")
+	r.add("b.txt")
+	r.write("c.txt", "new\n")
+	r.add("c.txt")
+	r.write("unstaged.txt", "not staged\n")
+
+	diff, err := r.client.GetStagedDiff()
+	if err != nil {
+		t.Fatalf("GetStagedDiff() error = %v", err)
+	}
+	assertFiles(t, diff, "M a.txt", "D b.txt", "A c.txt")
+
+	if diff.Stats.Files != 3 || diff.Stats.Additions != 2 || diff.Stats.Deletions != 2 {
+		t.Errorf("Stats = %+v, want 3 files, 2 additions, 2 deletions", diff.Stats)
+	}
+	if content := diff.Files[0].Content; !strings.Contains(content, "-two\n+2") || !strings.Contains(content, " one\n") {
+		t.Errorf("a.txt content = %q, want the change with context", content)
+	}
+}
+
+func TestUnstagedDiff(t *testing.T) {
+	r := newTestRepo(t, map[string]string{"a.txt": "one\n", "b.txt": "two\n"})
+
+	r.write("a.txt", "one\nmore\n")
+	r.remove("b.txt")
+	r.write("new/untracked.txt", "untracked\n")
+	r.write("staged.txt", "staged\n")
+	r.add("staged.txt")
+
+	diff, err := r.client.GetDiff()
+	if err != nil {
+		t.Fatalf("GetDiff() error = %v", err)
+	}
+	assertFiles(t, diff, "M a.txt", "D b.txt", "A new/untracked.txt")
+}
+
+func TestDiffReplacedByDirectory(t *testing.T) {
+	r := newTestRepo(t, map[string]string{"a": "file\n"})
+
+	r.remove("a")
+	r.write("a/b.txt", "nested\n")
+
+	diff, err := r.client.GetDiff()
+	if err != nil {
+		t.Fatalf("GetDiff() error = %v", err)
+	}
+	assertFiles(t, diff, "D a", "A a/b.txt")
+}
+
+func TestDiffRenames(t *testing.T) {
+	r := newTestRepo(t, map[string]string{"old.txt": "same content\n", "edited.txt": "one\ntwo\n"})
+
+	r.remove("old.txt")
+	r.add("old.txt")
+	r.write("new.txt", "same content\n")
+	r.add("new.txt")
+
+	// A rename with edits is not paired
+	r.remove("edited.txt")
+	r.add("edited.txt")
+	r.write("moved.txt", "one\ntwo\nthree\n")
+	r.add("moved.txt")
+
+	diff, err := r.client.GetStagedDiff()
+	if err != nil {
+		t.Fatalf("GetStagedDiff() error = %v", err)
+	}
+	assertFiles(t, diff, "D edited.txt", "A moved.txt", "R new.txt <- old.txt")
+
+	for _, file := range diff.Files {
+		if file.Status == "R" && (file.Additions != 0 || file.Deletions != 0) {
+			t.Errorf("rename counts %d additions and %d deletions, want none", file.Additions, file.Deletions)
+		}
+	}
+}
+
+func TestDiffBinary(t *testing.T) {
+	r := newTestRepo(t, map[string]string{"image.bin": "\x00\x01\x02"})
+
+	r.write("image.bin", "\x00\x01\x02\x03")
+	r.add("image.bin")
+
+	diff, err := r.client.GetStagedDiff()
+	if err != nil {
+		t.Fatalf("GetStagedDiff() error = %v", err)
+	}
+	assertFiles(t, diff, "M image.bin")
+
+	file := diff.Files[0]
+	if !strings.Contains(file.Content, "Binary files") {
+		t.Errorf("content = %q, want a binary files notice", file.Content)
+	}
+	if file.Additions != 0 || file.Deletions != 0 {
+		t.Errorf("binary file counts %d additions and %d deletions, want none", file.Additions, file.Deletions)
+	}
+}
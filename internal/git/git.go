@@ -2,7 +2,6 @@ package git
 
 import (
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,6 +10,7 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 )
 
 // Client represents a Git client for repository operations
@@ -76,6 +76,9 @@ type Commit struct {
 	Email     string
 	Date      time.Time
 	ShortHash string
+	// Files lists the paths changed by the commit. It is only set by
+	// GetCommitHistoryWithFiles.
+	Files []string
 }
 
 // Remote represents a git remote
@@ -210,117 +213,17 @@ func (c *Client) HasStagedChanges() (bool, error) {
 	return len(status.Staged) > 0, nil
 }
 
-// GetDiff returns the diff for unstaged changes
+// GetDiff returns the diff of the work tree against the index.
+// Untracked files are reported as new files.
 func (c *Client) GetDiff() (*Diff, error) {
 	return c.getDiff(false)
 }
 
-// GetStagedDiff returns the diff for staged changes
+// GetStagedDiff returns the diff of the index against HEAD
 func (c *Client) GetStagedDiff() (*Diff, error) {
 	return c.getDiff(true)
 }
 
-func (c *Client) getDiff(staged bool) (*Diff, error) {
-	head, err := c.repo.Head()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD: %w", err)
-	}
-
-	headCommit, err := c.repo.CommitObject(head.Hash())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
-	}
-
-	headTree, err := headCommit.Tree()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD tree: %w", err)
-	}
-
-	var compareTree *object.Tree
-	if staged {
-		// Compare staged changes (index vs HEAD)
-		// This is more complex and would require lower-level git operations
-		// For now, we'll use a simplified approach
-		compareTree = headTree
-	} else {
-		// Compare working directory vs HEAD
-		compareTree = headTree
-	}
-
-	// Get file changes
-	status, err := c.workTree.Status()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get status: %w", err)
-	}
-
-	diff := &Diff{
-		Files: []FileDiff{},
-		Stats: DiffStats{},
-	}
-
-	for filePath, fileStatus := range status {
-		if staged && fileStatus.Staging == git.Unmodified {
-			continue
-		}
-		if !staged && fileStatus.Worktree == git.Unmodified {
-			continue
-		}
-
-		fileDiff := FileDiff{
-			Path:   filePath,
-			Status: string(fileStatus.Worktree),
-		}
-
-		// Try to get the actual diff content
-		if content, err := c.getFileDiffContent(filePath, compareTree); err == nil {
-			fileDiff.Content = content
-			// Simple line counting (this could be improved)
-			lines := strings.Split(content, "\n")
-			for _, line := range lines {
-				if strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++") {
-					fileDiff.Additions++
-				} else if strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "---") {
-					fileDiff.Deletions++
-				}
-			}
-		}
-
-		diff.Files = append(diff.Files, fileDiff)
-		diff.Stats.Files++
-		diff.Stats.Additions += fileDiff.Additions
-		diff.Stats.Deletions += fileDiff.Deletions
-	}
-
-	return diff, nil
-}
-
-func (c *Client) getFileDiffContent(filePath string, compareTree *object.Tree) (string, error) {
-	// Get file from working directory
-	workingFile := filepath.Join(c.repoPath, filePath)
-	workingContent, err := os.ReadFile(workingFile)
-	if err != nil {
-		return "", err
-	}
-
-	// Get file from tree (if it exists)
-	var treeContent []byte
-	if file, err := compareTree.File(filePath); err == nil {
-		if reader, err := file.Reader(); err == nil {
-			defer reader.Close()
-			if content, err := io.ReadAll(reader); err == nil {
-				treeContent = content
-			}
-		}
-	}
-
-	// Simple diff representation (this could be improved with a proper diff algorithm)
-	if len(treeContent) == 0 {
-		return fmt.Sprintf("+++ %s\n%s", filePath, string(workingContent)), nil
-	}
-
-	return fmt.Sprintf("--- %s\n+++ %s\n%s", filePath, filePath, string(workingContent)), nil
-}
-
 // Add stages files for commit
 func (c *Client) Add(files ...string) error {
 	if len(files) == 0 {
@@ -507,6 +410,18 @@ func (c *Client) GetLastCommit() (*Commit, error) {
 
 // GetCommitHistory returns the commit history
 func (c *Client) GetCommitHistory(limit int) ([]Commit, error) {
+	return c.commitHistory(limit, false)
+}
+
+// GetCommitHistoryWithFiles returns up to limit commits from HEAD with the
+// paths each one changed. Merge commits are skipped.
+func (c *Client) GetCommitHistoryWithFiles(limit int) ([]Commit, error) {
+	return c.commitHistory(limit, true)
+}
+
+// commitHistory returns up to limit commits from HEAD. With files, merge
+// commits are skipped and the paths each commit changed are listed.
+func (c *Client) commitHistory(limit int, withFiles bool) ([]Commit, error) {
 	head, err := c.repo.Head()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get HEAD: %w", err)
@@ -521,10 +436,19 @@ func (c *Client) GetCommitHistory(limit int) ([]Commit, error) {
 	}
 	defer iter.Close()
 
-	count := 0
 	err = iter.ForEach(func(commit *object.Commit) error {
-		if limit > 0 && count >= limit {
-			return fmt.Errorf("limit reached") // Use error to break iteration
+		if limit > 0 && len(commits) >= limit {
+			return storer.ErrStop
+		}
+
+		var files []string
+		if withFiles {
+			if commit.NumParents() > 1 {
+				return nil
+			}
+			if files, err = commitFiles(commit); err != nil {
+				return err
+			}
 		}
 
 		commits = append(commits, Commit{
@@ -534,19 +458,52 @@ func (c *Client) GetCommitHistory(limit int) ([]Commit, error) {
 			Author:    commit.Author.Name,
 			Email:     commit.Author.Email,
 			Date:      commit.Author.When,
+			Files:     files,
 		})
-
-		count++
 		return nil
 	})
-
-	if err != nil && err.Error() != "limit reached" {
+	if err != nil {
 		return nil, fmt.Errorf("failed to iterate commits: %w", err)
 	}
 
 	return commits, nil
 }
 
+// commitFiles returns the paths changed by a commit relative to its first
+// parent, or all paths of a root commit
+func commitFiles(commit *object.Commit) ([]string, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree of %s: %w", commit.Hash.String()[:7], err)
+	}
+
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent of %s: %w", commit.Hash.String()[:7], err)
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, fmt.Errorf("failed to get tree of %s: %w", parent.Hash.String()[:7], err)
+		}
+	}
+
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s: %w", commit.Hash.String()[:7], err)
+	}
+
+	files := make([]string, 0, len(changes))
+	for _, change := range changes {
+		if change.To.Name != "" {
+			files = append(files, change.To.Name)
+		} else {
+			files = append(files, change.From.Name)
+		}
+	}
+	return files, nil
+}
+
 // IsClean checks if the working directory is clean
 func (c *Client) IsClean() (bool, error) {
 	status, err := c.workTree.Status()
@@ -0,0 +1,89 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commit records the staged changes with message and, for a merge, the
+// extra parents
+func (r *testRepo) commit(message string, parents ...plumbing.Hash) plumbing.Hash {
+	r.t.Helper()
+
+	options := &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(0, 0)},
+	}
+	if len(parents) > 0 {
+		head, err := r.client.repo.Head()
+		if err != nil {
+			r.t.Fatal(err)
+		}
+		options.Parents = append([]plumbing.Hash{head.Hash()}, parents...)
+		options.AllowEmptyCommits = true
+	}
+
+	hash, err := r.workTree.Commit(message, options)
+	if err != nil {
+		r.t.Fatalf("failed to commit: %v", err)
+	}
+	return hash
+}
+
+func messages(commits []Commit) []string {
+	var result []string
+	for _, commit := range commits {
+		result = append(result, commit.Message)
+	}
+	return result
+}
+
+func TestCommitHistory(t *testing.T) {
+	r := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+
+	r.write("b.txt", "b\n")
+	r.add("b.txt")
+	side := r.commit("feat: add b")
+
+	r.write("dir/c.txt", "c\n")
+	r.add("dir/c.txt")
+	r.write("a.txt", "changed\n")
+	r.add("a.txt")
+	r.commit("fix: change a and add c")
+	r.commit("Merge branch 'side'", side)
+
+	history, err := r.client.GetCommitHistory(0)
+	if err != nil {
+		t.Fatalf("GetCommitHistory() error = %v", err)
+	}
+	want := []string{"Merge branch 'side'", "fix: change a and add c", "feat: add b", "initial commit"}
+	if got := messages(history); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCommitHistory() = %q, want %q", got, want)
+	}
+
+	limited, err := r.client.GetCommitHistory(2)
+	if err != nil {
+		t.Fatalf("GetCommitHistory() error = %v", err)
+	}
+	if got := messages(limited); !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("GetCommitHistory(2) = %q, want %q", got, want[:2])
+	}
+
+	withFiles, err := r.client.GetCommitHistoryWithFiles(2)
+	if err != nil {
+		t.Fatalf("GetCommitHistoryWithFiles() error = %v", err)
+	}
+	if got := messages(withFiles); !reflect.DeepEqual(got, want[1:3]) {
+		t.Fatalf("GetCommitHistoryWithFiles(2) = %q, want %q without the merge", got, want[1:3])
+	}
+	if got := withFiles[0].Files; !reflect.DeepEqual(got, []string{"a.txt", "dir/c.txt"}) {
+		t.Errorf("Files = %q, want the paths the commit changed", got)
+	}
+	if history[1].Files != nil {
+		t.Errorf("GetCommitHistory() listed files %q", history[1].Files)
+	}
+}
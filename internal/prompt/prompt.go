@@ -0,0 +1,154 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Context is the data available to prompt templates. Templates use Go
+// text/template syntax, for example:
+//
+//	Branch: {{.Branch}}{{if .Ticket}} (ticket {{.Ticket}}){{end}}
+//	Allowed types: {{join .Types ", "}}
+//	{{range .Files}}- {{.Path}} (+{{.Additions}} -{{.Deletions}})
+//	{{end}}
+//	{{.Diff}}
+type Context struct {
+	// Diff is the formatted diff, or its summary when it is too large
+	Diff string
+	// Repo is the name of the repository directory
+	Repo string
+	// Branch is the current branch, or the short hash of a detached HEAD
+	Branch string
+	// Files lists the changed files
+	Files []File
+	// Stats totals the changes
+	Stats Stats
+	// Types and Scopes are the configured conventional commit types and
+	// scopes
+	Types  []string
+	Scopes []string
+	// RecentCommits holds the subjects of the latest commits, newest first
+	RecentCommits []string
+	// Examples holds well-formed commit messages from the repository's
+	// history, chosen as examples of its style when ai.examples is enabled
+	Examples []string
+	// Ticket is the issue reference found in the branch name, such as
+	// ABC-123 or #123
+	Ticket string
+	// Language is the language of generated text, such as German, or ""
+	// for English
+	Language string
+}
+
+// File describes a changed file
+type File struct {
+	Path      string
+	OldPath   string
+	Status    string
+	Additions int
+	Deletions int
+}
+
+// Stats totals the changes of a diff
+type Stats struct {
+	Files     int
+	Additions int
+	Deletions int
+}
+
+// legacyPlaceholders maps the placeholders of older templates to template
+// actions
+var legacyPlaceholders = strings.NewReplacer(
+	"{diff}", "{{.Diff}}",
+	"{changes}", "{{.Diff}}",
+)
+
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// Parse parses a prompt template. The {diff} and {changes} placeholders of
+// older templates are replaced by {{.Diff}}.
+func Parse(text string) (*template.Template, error) {
+	tmpl, err := template.New("prompt").Funcs(funcs).Parse(legacyPlaceholders.Replace(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Render renders a prompt template with data
+func Render(text string, data Context) (string, error) {
+	tmpl, err := Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+	if err := tmpl.Execute(&result, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return result.String(), nil
+}
+
+// Check reports whether a prompt template parses and only refers to fields
+// of Context
+func Check(text string) error {
+	_, err := Render(text, Context{})
+	return err
+}
+
+// languageNames maps ISO 639-1 codes to the names of languages
+var languageNames = map[string]string{
+	"ar": "Arabic", "cs": "Czech", "da": "Danish", "de": "German",
+	"el": "Greek", "en": "English", "es": "Spanish", "fi": "Finnish",
+	"fr": "French", "he": "Hebrew", "hi": "Hindi", "hu": "Hungarian",
+	"id": "Indonesian", "it": "Italian", "ja": "Japanese", "ko": "Korean",
+	"nb": "Norwegian", "nl": "Dutch", "no": "Norwegian", "pl": "Polish",
+	"pt": "Portuguese", "ro": "Romanian", "ru": "Russian", "sv": "Swedish",
+	"th": "Thai", "tr": "Turkish", "uk": "Ukrainian", "vi": "Vietnamese",
+	"zh": "Chinese",
+}
+
+// LanguageName returns the name of a language given as a code such as de
+// or pt-BR, or as a name. English, the default, gives "".
+func LanguageName(language string) string {
+	language = strings.TrimSpace(language)
+	base, region, _ := strings.Cut(strings.ReplaceAll(language, "_", "-"), "-")
+
+	known, ok := languageNames[strings.ToLower(base)]
+	switch {
+	case strings.EqualFold(language, "English") || known == "English":
+		return ""
+	case ok && region != "":
+		return known + " (" + strings.ToUpper(region) + ")"
+	case ok:
+		return known
+	}
+	return language
+}
+
+var (
+	// jiraTicketPattern matches issue keys such as ABC-123
+	jiraTicketPattern = regexp.MustCompile(`(?:^|[/_-])([A-Z][A-Z0-9]+-[0-9]+)(?:$|[/_-])`)
+	// issueNumberPattern matches issue numbers such as 123-fix-login or
+	// issue-123
+	issueNumberPattern = regexp.MustCompile(`(?:^|[/_-])([0-9]+)(?:$|[/_-])`)
+)
+
+// TicketFromBranch finds the issue reference in a branch name, such as
+// ABC-123 in feature/ABC-123-login or #42 in fix/42-crash
+func TicketFromBranch(branch string) string {
+	if match := jiraTicketPattern.FindStringSubmatch(branch); match != nil {
+		return match[1]
+	}
+	if match := issueNumberPattern.FindStringSubmatch(branch); match != nil {
+		return "#" + match[1]
+	}
+	return ""
+}
@@ -0,0 +1,257 @@
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// placeholderPrefix starts every placeholder, so that redacting a text twice
+// leaves the placeholders alone
+const placeholderPrefix = "[REDACTED:"
+
+// detector finds one kind of secret. When the pattern has a capture group
+// only the first group is the secret.
+type detector struct {
+	kind    string
+	pattern *regexp.Regexp
+}
+
+// builtinDetectors find common credential formats. More specific formats come
+// first so that they name the secret before a generic detector does.
+var builtinDetectors = []detector{
+	{"private-key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY(?: BLOCK)?-----(?:[\s\S]*?-----END [A-Z ]*PRIVATE KEY(?: BLOCK)?-----)?`)},
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`)},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"gitlab-token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"stripe-key", regexp.MustCompile(`\b(?:sk|rk|pk)_(?:live|test)_[A-Za-z0-9]{16,}`)},
+	{"anthropic-key", regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{"openai-key", regexp.MustCompile(`\bsk-(?:proj-|svcacct-)?[A-Za-z0-9_-]{20,}`)},
+	{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"url-password", regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:([^/\s:@]{3,})@`)},
+	{"password", regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|token|api_?key|access_?key|client_?secret|auth)["']?\s*[:=]\s*["']([^"'\s]{8,})["']`)},
+}
+
+var (
+	// entropyCandidate matches strings that may be random secrets
+	entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/=_-]{24,}`)
+	// checksumPattern matches the checksums of lock files, such as h1: in
+	// go.sum and sha512- in package-lock.json
+	checksumPattern = regexp.MustCompile(`(?:h1:|sha1-|sha256-|sha384-|sha512-)[A-Za-z0-9+/=_-]+$`)
+	// diffHeaderPattern matches the header of a file's diff and captures
+	// the path
+	diffHeaderPattern = regexp.MustCompile(`(?m)^diff --git a/.* b/(.+)$`)
+)
+
+// lockFiles are generated dependency lock files, whose hashes and
+// resolved URLs look random but are not secrets
+var lockFiles = map[string]bool{
+	"go.sum": true, "package-lock.json": true, "npm-shrinkwrap.json": true,
+	"yarn.lock": true, "pnpm-lock.yaml": true, "bun.lock": true,
+	"Cargo.lock": true, "Gemfile.lock": true, "composer.lock": true,
+	"poetry.lock": true, "Pipfile.lock": true, "uv.lock": true,
+	"flake.lock": true, "mix.lock": true, "Podfile.lock": true,
+	"packages.lock.json": true, "gradle.lockfile": true,
+}
+
+// minSecretRun is the shortest unbroken run of letters and digits in a
+// random secret. Identifiers, paths and UUIDs are split into shorter words
+// by separators.
+const minSecretRun = 16
+
+// Redactor replaces suspected secrets with placeholders. The placeholder of a
+// secret is derived from its hash, so the same secret always gets the same
+// placeholder and cached responses stay valid.
+type Redactor struct {
+	detectors []detector
+	entropy   float64
+}
+
+// New creates a redactor with the built-in detectors and the configured
+// patterns
+func New(cfg config.RedactConfig) (*Redactor, error) {
+	detectors := append([]detector{}, builtinDetectors...)
+
+	for _, pattern := range cfg.Patterns {
+		compiled, err := regexp.Compile(pattern.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern.Name, err)
+		}
+		kind := pattern.Name
+		if kind == "" {
+			kind = "custom"
+		}
+		detectors = append(detectors, detector{kind: kind, pattern: compiled})
+	}
+
+	return &Redactor{detectors: detectors, entropy: cfg.Entropy}, nil
+}
+
+// Redact replaces the suspected secrets in text and returns the kinds of
+// secrets found, sorted
+func (r *Redactor) Redact(text string) (string, []string) {
+	found := map[string]bool{}
+
+	for _, d := range r.detectors {
+		text = replace(text, d.pattern, func(secret string) string {
+			found[d.kind] = true
+			return placeholder(d.kind, secret)
+		})
+	}
+
+	if r.entropy > 0 {
+		skipped := lockFileSections(text)
+		text = replaceIndex(text, entropyCandidate, func(text string, start, end int) string {
+			secret := text[start:end]
+			if inSections(skipped, start) || checksumPattern.MatchString(text[max(0, start-8):end]) || !random(secret, r.entropy) {
+				return secret
+			}
+			found["high-entropy"] = true
+			return placeholder("high-entropy", secret)
+		})
+	}
+
+	kinds := make([]string, 0, len(found))
+	for kind := range found {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	return text, kinds
+}
+
+// replace replaces each match of pattern, or its first group if it has one,
+// with the result of fn
+func replace(text string, pattern *regexp.Regexp, fn func(secret string) string) string {
+	group := 0
+	if pattern.NumSubexp() > 0 {
+		group = 1
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[2*group], match[2*group+1]
+		if start < 0 || start < last || strings.HasPrefix(text[start:end], placeholderPrefix) {
+			continue
+		}
+		result.WriteString(text[last:start])
+		result.WriteString(fn(text[start:end]))
+		last = end
+	}
+	result.WriteString(text[last:])
+
+	return result.String()
+}
+
+// replaceIndex replaces each match of pattern with the result of fn, which
+// sees the match in context
+func replaceIndex(text string, pattern *regexp.Regexp, fn func(text string, start, end int) string) string {
+	var result strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringIndex(text, -1) {
+		result.WriteString(text[last:match[0]])
+		result.WriteString(fn(text, match[0], match[1]))
+		last = match[1]
+	}
+	result.WriteString(text[last:])
+
+	return result.String()
+}
+
+// lockFileSections returns the start and end of the diffs of lock files in
+// text, which may hold the diffs of several files
+func lockFileSections(text string) [][2]int {
+	var sections [][2]int
+	headers := diffHeaderPattern.FindAllStringSubmatchIndex(text, -1)
+	for i, header := range headers {
+		if !lockFiles[path.Base(text[header[2]:header[3]])] {
+			continue
+		}
+		end := len(text)
+		if i+1 < len(headers) {
+			end = headers[i+1][0]
+		}
+		sections = append(sections, [2]int{header[0], end})
+	}
+	return sections
+}
+
+func inSections(sections [][2]int, offset int) bool {
+	for _, section := range sections {
+		if offset >= section[0] && offset < section[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholder returns the stable placeholder of a secret
+func placeholder(kind, secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return placeholderPrefix + kind + ":" + hex.EncodeToString(sum[:3]) + "]"
+}
+
+// random reports whether s looks like a random secret: it mixes letters and
+// digits, is not hexadecimal, has a long run of letters and digits, and its
+// entropy reaches the threshold
+func random(s string, threshold float64) bool {
+	letters, digits, hexDigits := false, false, true
+	run, longestRun := 0, 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = true
+			run++
+		case r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+			letters = true
+			run++
+			if !(r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+				hexDigits = false
+			}
+		default:
+			// Dashes separate the groups of UUIDs and other hex IDs
+			if r != '-' {
+				hexDigits = false
+			}
+			run = 0
+		}
+		longestRun = max(longestRun, run)
+	}
+	if !letters || !digits || hexDigits || longestRun < minSecretRun {
+		return false
+	}
+
+	return Entropy(s) >= threshold
+}
+
+// Entropy returns the Shannon entropy of s in bits per character
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	counts := map[rune]int{}
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
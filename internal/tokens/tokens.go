@@ -0,0 +1,108 @@
+package tokens
+
+import (
+	"math"
+	"unicode"
+)
+
+// Tokenizer families known to the estimator
+const (
+	CL100K = "cl100k"
+	O200K  = "o200k"
+	Claude = "claude"
+	Gemini = "gemini"
+	Llama  = "llama"
+)
+
+// family describes how a tokenizer family splits text
+type family struct {
+	// lettersPerToken is the average length of a token within a word
+	lettersPerToken float64
+	// digitsPerToken is the number of digits grouped into one token
+	digitsPerToken float64
+}
+
+var families = map[string]family{
+	CL100K: {lettersPerToken: 4.0, digitsPerToken: 3},
+	O200K:  {lettersPerToken: 4.4, digitsPerToken: 3},
+	Claude: {lettersPerToken: 3.5, digitsPerToken: 1},
+	Gemini: {lettersPerToken: 4.0, digitsPerToken: 1},
+	Llama:  {lettersPerToken: 3.2, digitsPerToken: 1},
+}
+
+// defaultFamily is used for unknown tokenizers
+var defaultFamily = family{lettersPerToken: 3.5, digitsPerToken: 2}
+
+// Families returns the names of the known tokenizer families
+func Families() []string {
+	return []string{CL100K, O200K, Claude, Gemini, Llama}
+}
+
+// Known reports whether tokenizer is a known tokenizer family
+func Known(tokenizer string) bool {
+	_, ok := families[tokenizer]
+	return ok
+}
+
+// Estimate estimates the number of tokens text is split into by the given
+// tokenizer family. Words are split by average token length, runs of
+// punctuation by pairs, non-ASCII characters count as a token each, and
+// whitespace mostly merges with the following word.
+func Estimate(text string, tokenizer string) int {
+	f, ok := families[tokenizer]
+	if !ok {
+		f = defaultFamily
+	}
+
+	var count float64
+	letters, digits, spaces, symbols := 0, 0, 0, 0
+
+	flush := func() {
+		if letters > 0 {
+			count += math.Max(1, math.Round(float64(letters)/f.lettersPerToken))
+		}
+		count += math.Ceil(float64(digits) / f.digitsPerToken)
+		// A single space joins the next word; runs such as indentation
+		// are split into tokens of their own
+		if spaces > 1 {
+			count += math.Ceil(float64(spaces-1) / 4)
+		}
+		// Common operators such as := or ") merge into one token
+		count += math.Ceil(float64(symbols) / 2)
+		letters, digits, spaces, symbols = 0, 0, 0, 0
+	}
+
+	for _, r := range text {
+		switch {
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			if spaces > 0 || digits > 0 || symbols > 0 {
+				flush()
+			}
+			letters++
+		case unicode.IsDigit(r):
+			if letters > 0 || spaces > 0 || symbols > 0 {
+				flush()
+			}
+			digits++
+		case r == ' ' || r == '\t':
+			if letters > 0 || digits > 0 || symbols > 0 {
+				flush()
+			}
+			spaces++
+		case r == '\n' || r == '\r':
+			flush()
+			count++
+		case r < unicode.MaxASCII:
+			if letters > 0 || digits > 0 || spaces > 0 {
+				flush()
+			}
+			symbols++
+		default:
+			flush()
+			count++
+		}
+	}
+	flush()
+
+	return int(count)
+}
@@ -3,6 +3,7 @@ package ui
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"strings"
 	"time"
 
@@ -84,6 +85,11 @@ func (u *UI) Print(msg string, args ...interface{}) {
 	fmt.Printf(msg+"\n", args...)
 }
 
+// PrintStream prints a chunk of streamed text without a trailing newline
+func (u *UI) PrintStream(text string) {
+	HighlightColor.Print(text)
+}
+
 // StartSpinner starts a loading spinner with the given message
 func (u *UI) StartSpinner(msg string) {
 	if u.spinner != nil {
@@ -185,6 +191,76 @@ func (u *UI) Input(label string, defaultValue string) (string, error) {
 	return result, nil
 }
 
+// Editor returns the command of the user's editor from $VISUAL or $EDITOR,
+// or the first common editor installed, or "" if there is none
+func Editor() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(name); editor != "" {
+			return editor
+		}
+	}
+
+	for _, editor := range []string{"nano", "vim", "vi"} {
+		if _, err := exec.LookPath(editor); err == nil {
+			return editor
+		}
+	}
+	return ""
+}
+
+// Edit lets the user edit multi-line text in their editor, as git does for
+// commit messages: lines starting with # are dropped. Without an editor the
+// text is entered line by line instead.
+func (u *UI) Edit(label string, text string) (string, error) {
+	if !u.interactive {
+		return text, nil
+	}
+
+	editor := Editor()
+	if editor == "" {
+		u.Highlight(text)
+		return u.MultilineInput(label)
+	}
+
+	file, err := os.CreateTemp("", "ai-git-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	content := text + "\n\n# " + label + ". Lines starting with # are ignored.\n"
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	// The editor setting may carry arguments, such as "code --wait"
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], file.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited text: %w", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(edited), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
 // MultilineInput prompts the user for multiline text input
 func (u *UI) MultilineInput(label string) (string, error) {
 	if !u.interactive {
@@ -427,7 +503,7 @@ func (u *UI) PrintTable(headers []string, rows [][]string) {
 
 	// Print separator
 	for i := range headers {
-		u.Printf("%-*s", widths[i]+2, strings.Repeat("-", widths[i]))
+		fmt.Printf("%-*s", widths[i]+2, strings.Repeat("-", widths[i]))
 	}
 	u.Print("")
 
@@ -435,7 +511,7 @@ func (u *UI) PrintTable(headers []string, rows [][]string) {
 	for _, row := range rows {
 		for i, cell := range row {
 			if i < len(widths) {
-				u.Printf("%-*s", widths[i]+2, cell)
+				fmt.Printf("%-*s", widths[i]+2, cell)
 			}
 		}
 		u.Print("")
@@ -447,6 +523,19 @@ func (u *UI) Printf(format string, args ...interface{}) {
 	fmt.Printf(format+"\n", args...)
 }
 
+// PrintFinding prints a review finding, colored by severity
+func (u *UI) PrintFinding(severity, location, message string) {
+	c := InfoColor
+	switch severity {
+	case "high":
+		c = ErrorColor
+	case "medium":
+		c = WarningColor
+	}
+	c.Printf("  %-6s ", strings.ToUpper(severity))
+	fmt.Printf("%s %s\n", location, message)
+}
+
 // ShowProgress shows a simple progress indicator
 func (u *UI) ShowProgress(current, total int, message string) {
 	if total == 0 {
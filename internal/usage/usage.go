@@ -0,0 +1,243 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anans9/ai-git/internal/config"
+)
+
+// Record is a single ledger entry describing the tokens used by one request
+type Record struct {
+	Time             time.Time `json:"time"`
+	Repo             string    `json:"repo,omitempty"`
+	Command          string    `json:"command,omitempty"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	Estimated        bool      `json:"estimated,omitempty"`
+}
+
+// Ledger is an append-only log of token usage stored as JSON lines
+type Ledger struct {
+	path string
+}
+
+// NewLedger creates a ledger stored at path
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// DefaultPath returns the location of the usage ledger
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "ai-git-usage.jsonl")
+	}
+	return filepath.Join(home, ".local", "share", "ai-git", "usage.jsonl")
+}
+
+// Path returns the ledger file path
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Append adds a record to the ledger
+func (l *Ledger) Append(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage record: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage ledger: %w", err)
+	}
+
+	return nil
+}
+
+// Records returns the ledger entries recorded at or after since. Malformed
+// lines are skipped.
+func (l *Ledger) Records(since time.Time) ([]Record, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if record.Time.Before(since) {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+
+	return records, nil
+}
+
+// Clear removes all ledger entries
+func (l *Ledger) Clear() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear usage ledger: %w", err)
+	}
+	return nil
+}
+
+// defaultPrices lists list prices in USD per million tokens
+var defaultPrices = []config.ModelPrice{
+	{Model: "gpt-4", Input: 30, Output: 60},
+	{Model: "gpt-4-turbo", Input: 10, Output: 30},
+	{Model: "gpt-4o", Input: 2.5, Output: 10},
+	{Model: "gpt-4o-mini", Input: 0.15, Output: 0.6},
+	{Model: "gpt-3.5-turbo", Input: 0.5, Output: 1.5},
+	{Model: "claude-3-opus", Input: 15, Output: 75},
+	{Model: "claude-3-sonnet", Input: 3, Output: 15},
+	{Model: "claude-3-haiku", Input: 0.25, Output: 1.25},
+	{Model: "claude-3-5-sonnet", Input: 3, Output: 15},
+	{Model: "claude-3-5-haiku", Input: 0.8, Output: 4},
+	{Model: "gemini-1.5-flash", Input: 0.075, Output: 0.3},
+	{Model: "gemini-1.5-pro", Input: 1.25, Output: 5},
+}
+
+// PriceTable maps model names to prices
+type PriceTable map[string]config.ModelPrice
+
+// NewPriceTable creates a price table from the built-in prices, overridden
+// by the configured ones
+func NewPriceTable(prices []config.ModelPrice) PriceTable {
+	table := PriceTable{}
+	for _, price := range defaultPrices {
+		table[price.Model] = price
+	}
+	for _, price := range prices {
+		table[price.Model] = price
+	}
+	return table
+}
+
+// Lookup returns the price for model. Dated model versions such as
+// claude-3-sonnet-20240229 use the price of the longest matching prefix.
+func (t PriceTable) Lookup(model string) (config.ModelPrice, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+
+	var best config.ModelPrice
+	found := false
+	for name, price := range t {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best.Model) {
+			best = price
+			found = true
+		}
+	}
+	return best, found
+}
+
+// Cost returns the estimated cost of a record in USD
+func (t PriceTable) Cost(record Record) (float64, bool) {
+	price, ok := t.Lookup(record.Model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(record.PromptTokens)*price.Input + float64(record.CompletionTokens)*price.Output) / 1e6, true
+}
+
+// Totals aggregates a group of records
+type Totals struct {
+	Key              string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	Cost             float64
+	Unpriced         int
+}
+
+func (t *Totals) add(record Record, prices PriceTable) {
+	t.Requests++
+	t.PromptTokens += record.PromptTokens
+	t.CompletionTokens += record.CompletionTokens
+	t.TotalTokens += record.TotalTokens
+	if cost, ok := prices.Cost(record); ok {
+		t.Cost += cost
+	} else {
+		t.Unpriced++
+	}
+}
+
+// Summarize totals the records, grouped by the key returned for each record
+// and sorted by key
+func Summarize(records []Record, prices PriceTable, key func(Record) string) []Totals {
+	groups := map[string]*Totals{}
+	for _, record := range records {
+		k := key(record)
+		if groups[k] == nil {
+			groups[k] = &Totals{Key: k}
+		}
+		groups[k].add(record, prices)
+	}
+
+	result := make([]Totals, 0, len(groups))
+	for _, totals := range groups {
+		result = append(result, *totals)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+
+	return result
+}
+
+// Total totals all records
+func Total(records []Record, prices PriceTable) Totals {
+	totals := Totals{Key: "total"}
+	for _, record := range records {
+		totals.add(record, prices)
+	}
+	return totals
+}
+
+// FindRepo returns the root of the git repository containing dir, or an
+// empty string if dir is not inside a repository
+func FindRepo(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}